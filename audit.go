@@ -0,0 +1,160 @@
+package d2protocolparser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kelvyne/as3"
+	"github.com/kelvyne/as3/bytecode"
+)
+
+// auditContext is the number of instructions shown before and after an
+// unmatched sequence
+const auditContext = 4
+
+// ClassAudit lists the instruction sequences of a class serialize method that
+// no pattern consumed
+type ClassAudit struct {
+	Class     string
+	Namespace string
+	Sequences []Sequence
+}
+
+// Sequence is a getproperty/callpropvoid instruction sequence of a serialize
+// method that no pattern consumed
+type Sequence struct {
+	Start int // Start is the index of the first instruction of the sequence
+	End   int // End is the index of the last instruction of the sequence
+	// Disassembly contains the sequence and the instructions around it, the
+	// first line being the instruction at index Offset
+	Disassembly []string
+	Offset      int
+}
+
+func (s Sequence) String() string {
+	var buf bytes.Buffer
+	for i, line := range s.Disassembly {
+		idx := s.Offset + i
+		marker := " "
+		if idx >= s.Start && idx <= s.End {
+			marker = ">"
+		}
+		fmt.Fprintf(&buf, "%v %5d  %v\n", marker, idx, line)
+	}
+	return buf.String()
+}
+
+// multinameOperandInstrs lists the instructions whose first operand is a
+// multiname index
+var multinameOperandInstrs = map[string]bool{
+	"getproperty":    true,
+	"setproperty":    true,
+	"initproperty":   true,
+	"callproperty":   true,
+	"callpropvoid":   true,
+	"callproplex":    true,
+	"callsuper":      true,
+	"callsupervoid":  true,
+	"constructprop":  true,
+	"getlex":         true,
+	"findproperty":   true,
+	"findpropstrict": true,
+	"getsuper":       true,
+	"setsuper":       true,
+	"coerce":         true,
+	"astype":         true,
+	"istype":         true,
+}
+
+func (b *builder) formatInstr(abc *as3.AbcFile, instr bytecode.Instr) string {
+	name := instr.Model.Name
+	if len(instr.Operands) == 0 {
+		return name
+	}
+	pool := abc.Source.ConstantPool
+	op := instr.Operands[0]
+	switch {
+	case multinameOperandInstrs[name]:
+		return fmt.Sprintf("%v %v", name, pool.MultinameString(op))
+	case name == "pushstring":
+		return fmt.Sprintf("%v %q", name, pool.Strings[op])
+	case name == "pushint":
+		return fmt.Sprintf("%v %v", name, pool.Integers[op])
+	}
+	var ops []string
+	for _, o := range instr.Operands {
+		ops = append(ops, fmt.Sprint(o))
+	}
+	return fmt.Sprintf("%v %v", name, strings.Join(ops, " "))
+}
+
+// isAuditBoundary reports whether an instruction ends a statement
+func isAuditBoundary(name string) bool {
+	return strings.HasPrefix(name, "if") || strings.HasPrefix(name, "jump") ||
+		strings.HasPrefix(name, "return") || name == "label"
+}
+
+// auditSerialize finds the sequences ending with a callpropvoid that was not
+// consumed by a pattern. A sequence starts at the first unconsumed getproperty
+// following the previous statement, sequences without one are write calls on
+// locals such as BooleanByteWrapper boxes and are ignored. Calls to the
+// serialize methods of nested classes are not writes.
+func (b *builder) auditSerialize(abc *as3.AbcFile, class as3.Class, instrs []bytecode.Instr, consumed []bool) ClassAudit {
+	a := ClassAudit{Class: class.Name, Namespace: class.Namespace}
+	start := -1
+	for i, instr := range instrs {
+		name := instr.Model.Name
+		switch {
+		case consumed[i] || isAuditBoundary(name):
+			start = -1
+		case name == "getproperty" && start < 0:
+			start = i
+		case name == "callpropvoid":
+			if method, _ := b.operandName(abc, instr); start >= 0 && !strings.HasPrefix(method, "serialize") {
+				a.Sequences = append(a.Sequences, b.newSequence(abc, instrs, start, i))
+			}
+			start = -1
+		}
+	}
+	return a
+}
+
+func (b *builder) newSequence(abc *as3.AbcFile, instrs []bytecode.Instr, start, end int) Sequence {
+	from := start - auditContext
+	if from < 0 {
+		from = 0
+	}
+	to := end + auditContext + 1
+	if to > len(instrs) {
+		to = len(instrs)
+	}
+	s := Sequence{Start: start, End: end, Offset: from}
+	for _, instr := range instrs[from:to] {
+		s.Disassembly = append(s.Disassembly, b.formatInstr(abc, instr))
+	}
+	return s
+}
+
+// Audit extracts the classes of a DofusInvoker.swf read from r and lists, per
+// class, every getproperty/callpropvoid sequence of the serialize method that
+// no pattern consumed. Classes without such sequences are omitted. Extraction
+// errors do not stop the audit, use BuildWithDiagnostics to list them.
+func Audit(r io.Reader, opts Options) ([]ClassAudit, error) {
+	s, _, err := parseSwf(r)
+	if err != nil {
+		return nil, err
+	}
+	abcs, err := parseAbc(s, opts.ABCTags)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.SkipEnums = true
+	opts.SkipVersion = true
+	opts.SkipConstants = true
+	b := builder{abcFiles: abcs, opts: opts, keepGoing: true, auditing: true}
+	b.Build()
+	return b.audits, nil
+}
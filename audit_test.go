@@ -0,0 +1,113 @@
+package d2protocolparser
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kelvyne/as3"
+	"github.com/kelvyne/as3/bytecode"
+)
+
+func TestSequence_String(t *testing.T) {
+	s := Sequence{
+		Start:       5,
+		End:         6,
+		Offset:      4,
+		Disassembly: []string{"getlocal1", "getproperty foo", "callpropvoid writeFoo", "returnvoid"},
+	}
+	want := "      4  getlocal1\n" +
+		">     5  getproperty foo\n" +
+		">     6  callpropvoid writeFoo\n" +
+		"      7  returnvoid\n"
+	if got := s.String(); got != want {
+		t.Errorf("Sequence.String() = %q, want %q", got, want)
+	}
+}
+
+func TestIsAuditBoundary(t *testing.T) {
+	for _, name := range []string{"iflt", "ifne", "jump", "returnvoid", "label"} {
+		if !isAuditBoundary(name) {
+			t.Errorf("expected %v to be a boundary", name)
+		}
+	}
+	for _, name := range []string{"getproperty", "callpropvoid", "getlocal"} {
+		if isAuditBoundary(name) {
+			t.Errorf("expected %v not to be a boundary", name)
+		}
+	}
+}
+
+func Test_builder_auditSerialize(t *testing.T) {
+	names := []string{"", "x", "other", "writeFoo", "writeShort", "version", "serializeAs_VersionExtended"}
+	pool := bytecode.CpoolInfo{
+		Strings:    names,
+		Namespaces: []bytecode.NamespaceInfo{{}, {Kind: bytecode.NamespaceKindPackageNamespace}},
+	}
+	for i := range names {
+		pool.Multinames = append(pool.Multinames, bytecode.MultinameInfo{Kind: bytecode.MultinameKindQName, Name: uint32(i), Namespace: 1})
+	}
+	abc := &as3.AbcFile{Source: &bytecode.AbcFile{ConstantPool: pool}}
+	instr := func(name string, operands ...uint32) bytecode.Instr {
+		return bytecode.Instr{Model: bytecode.InstrModel{Name: name}, Operands: operands}
+	}
+	unknownWrite := func(prop uint32) []bytecode.Instr {
+		return []bytecode.Instr{
+			instr("getlocal1"), instr("getlocal0"), instr("getproperty", prop),
+			instr("pushbyte", 3), instr("callpropvoid", 3, 2), instr("returnvoid"),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		instrs []bytecode.Instr
+		want   int
+	}{
+		{"unknown write of a field", unknownWrite(1), 1},
+		{"unknown write of another property", unknownWrite(2), 1},
+		{"scalar write", []bytecode.Instr{
+			instr("getlocal1"), instr("getlocal0"), instr("getproperty", 1), instr("callpropvoid", 4, 1), instr("returnvoid"),
+		}, 0},
+		{"nested class", []bytecode.Instr{
+			instr("getlocal0"), instr("getproperty", 5), instr("getlocal1"), instr("callpropvoid", 6, 1), instr("returnvoid"),
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := map[string]*Field{
+				"x":       {Name: "x", Type: "int", Order: -1},
+				"version": {Name: "version", Type: "VersionExtended", Order: -1},
+			}
+			b := &builder{}
+			consumed, _, err := b.extractSerializeMethods(abc, as3.Class{}, tt.instrs, fields)
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			a := b.auditSerialize(abc, as3.Class{}, tt.instrs, consumed)
+			if len(a.Sequences) != tt.want {
+				t.Errorf("builder.auditSerialize() = %v sequences, want %v", len(a.Sequences), tt.want)
+			}
+		})
+	}
+}
+
+func TestAudit(t *testing.T) {
+	f, err := os.Open("./fixtures/DofusInvoker.swf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	audits, err := Audit(f, Options{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	for _, a := range audits {
+		for _, s := range a.Sequences {
+			last := s.Disassembly[s.End-s.Offset]
+			if !strings.HasPrefix(last, "callpropvoid") {
+				t.Errorf("%v: expected sequence to end with callpropvoid, got %v", a.Class, last)
+			}
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package d2protocolparser
 
 import (
+	"fmt"
 	"os"
 
 	"io"
+	"io/ioutil"
 
 	"bytes"
 
@@ -17,10 +19,14 @@ import (
 // Protocol represents the Dofus 2 Protocol and contains
 // every messages and types
 type Protocol struct {
-	Messages []Class
-	Types    []Class
-	Enums    []Enum
-	Version  Version
+	Messages  []Class
+	Types     []Class
+	Enums     []Enum
+	Version   Version
+	Constants Constants
+	// Container is the container of the swf the Protocol was built from, it
+	// is empty for protocols read from JSON
+	Container Container
 }
 
 // Enum represents a Dofus 2 Protocol Enumeration Class
@@ -32,17 +38,53 @@ type Enum struct {
 // EnumValue represents a single Enumeration Values
 type EnumValue struct {
 	Name  string
-	Value int32
+	Value Value // Value is usually an int32, some enumerations hold uint32, float64 or string values
+}
+
+// Type returns the type of the values of e: the type of its values when they
+// share one, "uint32" or "int64" for a mix of int32 and uint32 values,
+// "float64" for a mix of numbers and an empty string otherwise. Enumerations
+// without values are int32.
+func (e Enum) Type() string {
+	types := map[string]bool{}
+	negative := false
+	for _, v := range e.Values {
+		types[v.Value.Type] = true
+		negative = negative || v.Value.Int < 0
+	}
+	switch {
+	case len(types) == 0:
+		return "int32"
+	case len(types) == 1:
+		return e.Values[0].Value.Type
+	}
+	for t := range types {
+		if t != "int32" && t != "uint32" && t != "float64" {
+			return ""
+		}
+	}
+	switch {
+	case types["float64"]:
+		return "float64"
+	case negative:
+		return "int64"
+	}
+	return "uint32"
 }
 
 // Class represents a Dofus 2 Protocol class
 type Class struct {
 	Name        string
 	Namespace   string
-	Parent      string
+	Parent      string // Parent is empty for classes extending Object or NetworkMessage, see Protocol.Parent
 	Fields      []Field
 	ProtocolID  uint16
 	UseHashFunc bool
+	Hash        Hash      // Hash describes the hash appended by pack when UseHashFunc is set
+	Direction   Direction // Direction is only set for messages
+	// Boxes are the BooleanByteWrapper bytes found in the serialize method,
+	// see BBWGroups
+	Boxes []BBWGroup
 }
 
 // Field represents a class field
@@ -52,6 +94,11 @@ type Field struct {
 	WriteMethod string
 	Method      string // Method contains the name of the method that should be used for scalar types
 
+	// Order is the index of the field in the order the serialize method
+	// writes it. Class.Fields is sorted by Order, fields that are never
+	// written come last.
+	Order int
+
 	IsVector          bool
 	IsDynamicLength   bool
 	Length            uint32
@@ -61,6 +108,56 @@ type Field struct {
 
 	UseBBW      bool // Use BooleanByteWrapper
 	BBWPosition uint
+
+	// Min and Max are the bounds checked by the deserialize method, on each
+	// element for vectors. MinOp ("<" or "<=") and MaxOp (">" or ">=") are
+	// the comparisons rejecting a value, they are empty when there is no check.
+	Min   float64
+	MinOp string
+	Max   float64
+	MaxOp string
+}
+
+// BBWGroup is a BooleanByteWrapper byte packing up to 8 bool fields. The
+// byte is written in place of its members.
+type BBWGroup struct {
+	Index int // Index is the index in Class.Fields of the first member
+	// Register is the local of the serialize method holding the byte
+	Register uint32
+	// Position is the number of fields the serialize method writes before the
+	// byte, -1 when the byte is never written
+	Position int
+	Members  []BBWMember
+}
+
+// BBWMember is a bool field of a BBWGroup
+type BBWMember struct {
+	Field string
+	Bit   uint // Bit is the offset of the flag in the byte
+}
+
+// BBWGroups returns the BooleanByteWrapper bytes of the class in the order
+// they are written. Classes built without Boxes, by hand or from older
+// documents, start a new byte with each flag at bit 0 and write it in place of
+// its flags.
+func (c Class) BBWGroups() []BBWGroup {
+	if c.Boxes != nil {
+		return c.Boxes
+	}
+	var groups []BBWGroup
+	for i, f := range c.Fields {
+		if !f.UseBBW {
+			continue
+		}
+		bit := f.BBWPosition % 8
+		if len(groups) == 0 || bit == 0 {
+			groups = append(groups, BBWGroup{Index: i})
+		}
+		g := &groups[len(groups)-1]
+		g.Members = append(g.Members, BBWMember{f.Name, bit})
+		g.Position = g.Index + len(g.Members)
+	}
+	return groups
 }
 
 // Version represents a Dofus 2 Protocol version
@@ -72,40 +169,92 @@ type Version struct {
 	Patch    uint
 }
 
+// Options controls how a Protocol is built. The zero value extracts
+// everything and verifies the result, which is what Build does.
+type Options struct {
+	SkipVerify    bool // SkipVerify disables the Verify pass on the built Protocol
+	SkipMessages  bool
+	SkipTypes     bool
+	SkipEnums     bool
+	SkipVersion   bool
+	SkipConstants bool
+
+	// ABCTags lists the names of the DoABC tags to extract from. Every DoABC
+	// tag is used when it is empty.
+	ABCTags []string
+
+	// FatalWarnings makes BuildWithDiagnostics fail on warnings too
+	FatalWarnings bool
+}
+
 type builder struct {
-	abcFile *as3.AbcFile
+	abcFiles []*as3.AbcFile // abcFiles contains every selected DoABC tag
+	opts     Options
+
+	keepGoing   bool // keepGoing records extraction errors as diagnostics instead of failing
+	diagnostics []Diagnostic
+
+	auditing bool // auditing records the instruction sequences no pattern consumed
+	audits   []ClassAudit
 }
 
-func parseSwf(r io.ReadSeeker) (*swf.Swf, error) {
-	s, err := swf.Parse(r)
+func parseSwf(r io.Reader) (*swf.Swf, Container, error) {
+	data, err := ioutil.ReadAll(r)
 	if err != nil {
-		return nil, newError(err, "swf parsing failed")
+		return nil, "", newError(err, "swf reading failed")
 	}
-	return &s, nil
+	data, c, err := decompressSwf(data)
+	if err != nil {
+		return nil, c, newError(err, "swf decompression failed")
+	}
+	s, err := swf.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, c, newError(err, fmt.Sprintf("%v swf parsing failed", c))
+	}
+	return &s, c, nil
 }
 
-func parseAbc(s *swf.Swf) (*as3.AbcFile, error) {
+func parseAbc(s *swf.Swf, names []string) ([]*as3.AbcFile, error) {
+	selected := func(name string) bool {
+		if len(names) == 0 {
+			return true
+		}
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	var files []*as3.AbcFile
 	for _, tag := range s.Tags {
 		if tag.Code() != swf.CodeTagDoABC {
 			continue
 		}
 		doAbc := tag.(*swf.TagDoABC)
-		if doAbc.Name != "frame1" {
+		if !selected(doAbc.Name) {
 			continue
 		}
 
 		abc, err := bytecode.Parse(bytecode.NewReader(bytes.NewReader(doAbc.ABCData)))
 		if err != nil {
-			return nil, newError(err, "abc parsing failed")
+			return nil, newError(err, fmt.Sprintf("abc parsing of %v failed", doAbc.Name))
 		}
 
 		l, err := as3.Link(&abc)
 		if err != nil {
-			return nil, newError(err, "abc linking failed")
+			return nil, newError(err, fmt.Sprintf("abc linking of %v failed", doAbc.Name))
 		}
-		return &l, nil
+		files = append(files, &l)
 	}
-	return nil, newError(nil, "swf file does not contain frame1 tag")
+	if len(files) == 0 {
+		if len(names) == 0 {
+			return nil, newError(nil, "swf file does not contain DoABC tags")
+		}
+		return nil, newError(nil, fmt.Sprintf("swf file does not contain DoABC tags %v", strings.Join(names, ", ")))
+	}
+	return files, nil
 }
 
 // Build reads the DofusInvoker.swf at the given path and build a list of
@@ -115,23 +264,42 @@ func Build(path string) (*Protocol, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
-	s, err := parseSwf(file)
+	return BuildFromReader(file, Options{})
+}
+
+// BuildFromBytes builds the Protocol from an in-memory DofusInvoker.swf
+func BuildFromBytes(data []byte, opts Options) (*Protocol, error) {
+	return buildFromSwf(bytes.NewReader(data), opts)
+}
+
+// BuildFromReader builds the Protocol from a DofusInvoker.swf read from r
+func BuildFromReader(r io.Reader, opts Options) (*Protocol, error) {
+	return buildFromSwf(r, opts)
+}
+
+func buildFromSwf(r io.Reader, opts Options) (*Protocol, error) {
+	s, c, err := parseSwf(r)
 	if err != nil {
 		return nil, err
 	}
 
-	a, err := parseAbc(s)
+	a, err := parseAbc(s, opts.ABCTags)
 	if err != nil {
 		return nil, err
 	}
 
-	b := builder{abcFile: a}
+	b := builder{abcFiles: a, opts: opts}
 	p, err := b.Build()
 	if err != nil {
 		return nil, newError(err, "protocol build failed")
 	}
+	p.Container = c
 
+	if opts.SkipVerify {
+		return &p, nil
+	}
 	if err = Verify(&p); err != nil {
 		return nil, newError(err, "verification error")
 	}
@@ -144,17 +312,48 @@ const (
 	enumPrefix    = "com.ankamagames.dofus.network.enums"
 )
 
+// abcClass is a class paired with the abc file defining it
+type abcClass struct {
+	abc   *as3.AbcFile
+	class as3.Class
+}
+
+// classes returns the classes of every abc file. A class defined in several
+// abc files is only returned from the first one.
+func (b *builder) classes() []abcClass {
+	var classes []abcClass
+	seen := map[string]bool{}
+	for _, a := range b.abcFiles {
+		for _, class := range a.Classes {
+			qname := class.Namespace + "." + class.Name
+			if seen[qname] {
+				continue
+			}
+			seen[qname] = true
+			classes = append(classes, abcClass{a, class})
+		}
+	}
+	return classes
+}
+
+// Build extracts the protocol from every abc file. A class defined in
+// several abc files is extracted from the first one.
 func (b *builder) Build() (Protocol, error) {
 	var types []Class
 	var messages []Class
 	var enums []Enum
-	for _, class := range b.abcFile.Classes {
-		isMessage := strings.HasPrefix(class.Namespace, messagePrefix)
-		isType := strings.HasPrefix(class.Namespace, typePrefix)
+	for _, ac := range b.classes() {
+		abc, class := ac.abc, ac.class
+		isMessage := strings.HasPrefix(class.Namespace, messagePrefix) && !b.opts.SkipMessages
+		isType := strings.HasPrefix(class.Namespace, typePrefix) && !b.opts.SkipTypes
 		if isType || isMessage {
-			c, err := b.ExtractClass(class)
+			c, err := b.ExtractClass(abc, class)
 			if err != nil {
-				return Protocol{}, err
+				if !b.keepGoing {
+					return Protocol{}, err
+				}
+				b.diagnostics = append(b.diagnostics, newDiagnostic(SeverityError, class.Name, class.Namespace, err))
+				continue
 			}
 			switch {
 			case isType:
@@ -162,17 +361,57 @@ func (b *builder) Build() (Protocol, error) {
 			case isMessage:
 				messages = append(messages, c)
 			}
-		} else if strings.HasPrefix(class.Namespace, enumPrefix) {
-			e, err := b.ExtractEnum(class)
+		} else if strings.HasPrefix(class.Namespace, enumPrefix) && !b.opts.SkipEnums {
+			e, err := b.ExtractEnum(abc, class)
 			if err != nil {
-				return Protocol{}, err
+				if !b.keepGoing {
+					return Protocol{}, err
+				}
+				b.diagnostics = append(b.diagnostics, newDiagnostic(SeverityError, class.Name, class.Namespace, err))
+				continue
 			}
 			enums = append(enums, e)
 		}
 	}
-	v, err := b.ExtractVersion()
-	if err != nil {
-		return Protocol{}, err
+	if !b.opts.SkipMessages {
+		if err := b.setDirections(messages); err != nil {
+			if !b.keepGoing {
+				return Protocol{}, err
+			}
+			b.diagnostics = append(b.diagnostics, newDiagnostic(SeverityError, receiverName, receiverNamespace, err))
+		}
+	}
+
+	var v Version
+	if !b.opts.SkipVersion {
+		var err error
+		if v, err = b.extractVersion(); err != nil {
+			if !b.keepGoing {
+				return Protocol{}, err
+			}
+			b.diagnostics = append(b.diagnostics, newDiagnostic(SeverityError, buildInfosName, buildInfosNamespace, err))
+		}
+	}
+	var constants Constants
+	if !b.opts.SkipConstants {
+		var err error
+		if constants, err = b.extractConstants(); err != nil {
+			if !b.keepGoing {
+				return Protocol{}, err
+			}
+			b.diagnostics = append(b.diagnostics, newDiagnostic(SeverityError, "", constantsNamespace, err))
+		}
+	}
+	return Protocol{messages, types, enums, v, constants, ""}, nil
+}
+
+// extractVersion extracts the version from the first abc file defining BuildInfos
+func (b *builder) extractVersion() (Version, error) {
+	for _, a := range b.abcFiles {
+		v, err := b.ExtractVersion(a)
+		if err != ErrExtractNoBuildInfos {
+			return v, err
+		}
 	}
-	return Protocol{messages, types, enums, v}, nil
+	return Version{}, ErrExtractNoBuildInfos
 }
@@ -1,8 +1,12 @@
 package d2protocolparser
 
 import (
+	"io/ioutil"
+	"os"
 	"reflect"
 	"testing"
+
+	"github.com/kelvyne/as3"
 )
 
 func BenchmarkBuild(b *testing.B) {
@@ -37,3 +41,187 @@ func TestBuild_NewVersion(t *testing.T) {
 		t.Errorf("expected %v, got %v", expectedVersion, p.Version)
 	}
 }
+
+func TestBuildFromBytes(t *testing.T) {
+	data, err := ioutil.ReadFile("./fixtures/DofusInvoker.swf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := Build("./fixtures/DofusInvoker.swf")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	p, err := BuildFromBytes(data, Options{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("BuildFromBytes and Build protocols differ")
+	}
+}
+
+func TestBuildFromReader_Options(t *testing.T) {
+	f, err := os.Open("./fixtures/DofusInvoker.swf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p, err := BuildFromReader(f, Options{SkipVerify: true, SkipMessages: true, SkipVersion: true})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if len(p.Messages) != 0 {
+		t.Errorf("expected no messages, got %v", len(p.Messages))
+	}
+	if len(p.Types) == 0 || len(p.Enums) == 0 {
+		t.Errorf("expected types and enums to be extracted")
+	}
+	if !reflect.DeepEqual(p.Version, Version{}) {
+		t.Errorf("expected empty version, got %v", p.Version)
+	}
+}
+
+func TestBuildFromReader_ABCTags(t *testing.T) {
+	data, err := ioutil.ReadFile("./fixtures/DofusInvoker.swf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := BuildFromBytes(data, Options{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	frame1, err := BuildFromBytes(data, Options{ABCTags: []string{"frame1"}})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if len(frame1.Messages) != len(all.Messages) || len(frame1.Types) != len(all.Types) {
+		t.Errorf("expected frame1 to contain every network class")
+	}
+
+	if _, err = BuildFromBytes(data, Options{ABCTags: []string{"missing"}}); err == nil {
+		t.Errorf("expected error for missing DoABC tag")
+	}
+}
+
+func TestBuildWithDiagnostics(t *testing.T) {
+	f, err := os.Open("./fixtures/DofusInvoker.swf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	expected, err := Build("./fixtures/DofusInvoker.swf")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	p, diags, err := BuildWithDiagnostics(f, Options{FatalWarnings: true})
+	if err != nil {
+		t.Fatalf("expected nil, got %v (%v)", err, diags)
+	}
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("expected the same protocol as Build")
+	}
+}
+
+func Test_builder_classes(t *testing.T) {
+	class := func(ns, name string) as3.Class {
+		return as3.Class{Namespace: ns, Name: name}
+	}
+	first := &as3.AbcFile{Classes: []as3.Class{class("a", "A"), class("a", "B")}}
+	second := &as3.AbcFile{Classes: []as3.Class{class("a", "B"), class("b", "B")}}
+	b := &builder{abcFiles: []*as3.AbcFile{first, second}}
+
+	want := []abcClass{
+		{first, class("a", "A")},
+		{first, class("a", "B")},
+		{second, class("b", "B")},
+	}
+	if got := b.classes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("builder.classes() = %v, want %v", got, want)
+	}
+}
+
+func TestClass_BBWGroups(t *testing.T) {
+	bbw := func(name string, pos uint) Field {
+		return Field{Name: name, Type: "bool", UseBBW: true, BBWPosition: pos}
+	}
+	flags := func(n int) []Field {
+		var fields []Field
+		for i := 0; i < n; i++ {
+			fields = append(fields, bbw(string(rune('a'+i)), uint(i%8)))
+		}
+		return fields
+	}
+	tests := []struct {
+		name   string
+		fields []Field
+		want   []BBWGroup
+	}{
+		{"none", []Field{{Name: "x", Type: "int8"}}, nil},
+		{
+			"after a field",
+			[]Field{{Name: "x", Type: "int8"}, bbw("a", 0), bbw("b", 1)},
+			[]BBWGroup{{1, 0, 3, []BBWMember{{"a", 0}, {"b", 1}}}},
+		},
+		{
+			"two bytes",
+			flags(9),
+			[]BBWGroup{
+				{0, 0, 8, []BBWMember{{"a", 0}, {"b", 1}, {"c", 2}, {"d", 3}, {"e", 4}, {"f", 5}, {"g", 6}, {"h", 7}}},
+				{8, 0, 9, []BBWMember{{"i", 0}}},
+			},
+		},
+		{
+			"absolute positions",
+			[]Field{bbw("a", 7), bbw("b", 8), bbw("c", 9)},
+			[]BBWGroup{{0, 0, 1, []BBWMember{{"a", 7}}}, {1, 0, 3, []BBWMember{{"b", 0}, {"c", 1}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Class{Name: "C", Fields: tt.fields}
+			if got := c.BBWGroups(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Class.BBWGroups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	boxes := []BBWGroup{{0, 2, -1, []BBWMember{{"a", 0}}}}
+	c := Class{Name: "C", Fields: []Field{bbw("a", 0), bbw("b", 1)}, Boxes: boxes}
+	if got := c.BBWGroups(); !reflect.DeepEqual(got, boxes) {
+		t.Errorf("Class.BBWGroups() = %v, want the extracted %v", got, boxes)
+	}
+}
+
+func TestEnum_Type(t *testing.T) {
+	value := func(typ string, n int64) EnumValue {
+		return EnumValue{Value: Value{Type: typ, Int: n}}
+	}
+	tests := []struct {
+		name   string
+		values []EnumValue
+		want   string
+	}{
+		{"empty", nil, "int32"},
+		{"int32", []EnumValue{value("int32", -1), value("int32", 2)}, "int32"},
+		{"string", []EnumValue{value("string", 0)}, "string"},
+		{"unsigned", []EnumValue{value("int32", 1), value("uint32", 4294967295)}, "uint32"},
+		{"signed", []EnumValue{value("int32", -1), value("uint32", 4294967295)}, "int64"},
+		{"numbers", []EnumValue{value("int32", -1), value("float64", 0)}, "float64"},
+		{"mixed", []EnumValue{value("int32", 1), value("string", 0)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Enum{Values: tt.values}).Type(); got != tt.want {
+				t.Errorf("Enum.Type() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/kelvyne/d2protocolparser"
+	"github.com/kelvyne/d2protocolparser/codegen/golang"
+	"github.com/kelvyne/d2protocolparser/protodiff"
+)
+
+func runDiff(args []string, stdout io.Writer) (int, error) {
+	fs := newFlagSet("diff")
+	asJSON := fs.Bool("json", false, "write the report as JSON")
+	if err := parseFlags(fs, args, 2); err != nil {
+		return exitError, err
+	}
+	from, err := loadProtocol(fs.Arg(0), d2protocolparser.Options{})
+	if err != nil {
+		return exitError, err
+	}
+	to, err := loadProtocol(fs.Arg(1), d2protocolparser.Options{})
+	if err != nil {
+		return exitError, err
+	}
+
+	r := protodiff.Diff(from, to)
+	if *asJSON {
+		e := json.NewEncoder(stdout)
+		e.SetIndent("", "  ")
+		err = e.Encode(r)
+	} else {
+		err = r.WriteText(stdout)
+	}
+	if err != nil {
+		return exitError, err
+	}
+	if len(r.Changes) != 0 {
+		return exitFailure, nil
+	}
+	return exitOK, nil
+}
+
+func runGen(args []string, stdout io.Writer) (int, error) {
+	fs := newFlagSet("gen")
+	out := fs.String("o", ".", "output directory")
+	pkg := fs.String("package", "", "generated package name")
+	if err := parseFlags(fs, args, 2); err != nil {
+		return exitError, err
+	}
+	if lang := fs.Arg(0); lang != "go" {
+		return exitError, errUsage(fmt.Sprintf("unsupported language %v, only go is supported", lang))
+	}
+	p, err := loadProtocol(fs.Arg(1), d2protocolparser.Options{})
+	if err != nil {
+		return exitError, err
+	}
+	if err = golang.GenerateDir(p, *out, golang.Options{Package: *pkg}); err != nil {
+		return exitError, err
+	}
+	return exitOK, nil
+}
+
+func runLookup(args []string, stdout io.Writer) (int, error) {
+	fs := newFlagSet("lookup")
+	if err := parseFlags(fs, args, 2); err != nil {
+		return exitError, err
+	}
+	p, err := loadProtocol(fs.Arg(0), d2protocolparser.Options{})
+	if err != nil {
+		return exitError, err
+	}
+
+	key := fs.Arg(1)
+	id, err := strconv.ParseUint(key, 10, 16)
+	isID := err == nil
+	matches := func(c d2protocolparser.Class) bool {
+		if isID {
+			return uint64(c.ProtocolID) == id
+		}
+		return c.Name == key
+	}
+
+	var buf bytes.Buffer
+	for _, c := range p.Messages {
+		if matches(c) {
+			writeClass(&buf, "message", c)
+		}
+	}
+	for _, c := range p.Types {
+		if matches(c) {
+			writeClass(&buf, "type", c)
+		}
+	}
+	for _, e := range p.Enums {
+		if !isID && e.Name == key {
+			writeEnum(&buf, e)
+		}
+	}
+	if buf.Len() == 0 {
+		fmt.Fprintf(stdout, "%v not found\n", key)
+		return exitFailure, nil
+	}
+	_, err = buf.WriteTo(stdout)
+	return exitOK, err
+}
+
+func runAudit(args []string, stdout io.Writer) (int, error) {
+	fs := newFlagSet("audit")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return exitError, err
+	}
+	r, err := openSwf(fs.Arg(0))
+	if err != nil {
+		return exitError, err
+	}
+	defer r.Close()
+
+	audits, err := d2protocolparser.Audit(r, d2protocolparser.Options{})
+	if err != nil {
+		return exitError, err
+	}
+	var buf bytes.Buffer
+	for _, a := range audits {
+		fmt.Fprintf(&buf, "%v.%v: %v unmatched sequences\n", a.Namespace, a.Class, len(a.Sequences))
+		for _, s := range a.Sequences {
+			fmt.Fprintf(&buf, "%v\n", s)
+		}
+	}
+	if _, err = buf.WriteTo(stdout); err != nil {
+		return exitError, err
+	}
+	if len(audits) != 0 {
+		return exitFailure, nil
+	}
+	return exitOK, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kelvyne/d2protocolparser"
+	"github.com/kelvyne/d2protocolparser/protojson"
+)
+
+func versionString(v d2protocolparser.Version) string {
+	return fmt.Sprintf("%v.%v.%v.%v.%v", v.Major, v.Minor, v.Release, v.Revision, v.Patch)
+}
+
+func runDump(args []string, stdout io.Writer) (int, error) {
+	fs := newFlagSet("dump")
+	format := fs.String("format", "json", "output format: json, yaml or text")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return exitError, err
+	}
+
+	var write func(io.Writer, *d2protocolparser.Protocol) error
+	switch *format {
+	case "json":
+		write = protojson.Encode
+	case "yaml":
+		write = writeYAML
+	case "text":
+		write = writeText
+	default:
+		return exitError, errUsage(fmt.Sprintf("unknown format %v", *format))
+	}
+
+	p, err := loadProtocol(fs.Arg(0), d2protocolparser.Options{})
+	if err != nil {
+		return exitError, err
+	}
+	if err = write(stdout, p); err != nil {
+		return exitError, err
+	}
+	return exitOK, nil
+}
+
+func writeText(w io.Writer, p *d2protocolparser.Protocol) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Dofus %v", versionString(p.Version))
+	if p.Container != "" {
+		fmt.Fprintf(&buf, " (%v swf)", p.Container)
+	}
+	buf.WriteString("\n")
+	for _, c := range p.Constants.Values {
+		fmt.Fprintf(&buf, "  %v.%v = %v\n", c.Class, c.Name, c.Value)
+	}
+	for _, c := range p.Messages {
+		buf.WriteString("\n")
+		writeClass(&buf, "message", c)
+	}
+	for _, c := range p.Types {
+		buf.WriteString("\n")
+		writeClass(&buf, "type", c)
+	}
+	for _, e := range p.Enums {
+		buf.WriteString("\n")
+		writeEnum(&buf, e)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func writeClass(buf *bytes.Buffer, kind string, c d2protocolparser.Class) {
+	fmt.Fprintf(buf, "%v %v (%v)", kind, c.Name, c.ProtocolID)
+	if c.Parent != "" {
+		fmt.Fprintf(buf, " extends %v", c.Parent)
+	}
+	if c.UseHashFunc {
+		buf.WriteString(" hashed")
+		if c.Hash.Position != d2protocolparser.HashPositionUnknown {
+			fmt.Fprintf(buf, " by %v appended to the %v", c.Hash.Function, c.Hash.Position)
+		}
+	}
+	if c.Direction != d2protocolparser.DirectionUnknown {
+		fmt.Fprintf(buf, " from %v", c.Direction)
+	}
+	fmt.Fprintf(buf, "\n  namespace %v\n", c.Namespace)
+	for _, f := range c.Fields {
+		fmt.Fprintf(buf, "  %v %v", f.Name, f.Type)
+		var attrs []string
+		if f.WriteMethod != "" {
+			attrs = append(attrs, f.WriteMethod)
+		}
+		switch {
+		case f.IsVector && f.IsDynamicLength:
+			attrs = append(attrs, "vector length "+f.WriteLengthMethod)
+		case f.IsVector:
+			attrs = append(attrs, fmt.Sprintf("vector length %v", f.Length))
+		}
+		if f.UseTypeManager {
+			attrs = append(attrs, "type manager")
+		}
+		if f.UseBBW {
+			attrs = append(attrs, fmt.Sprintf("bbw %v", f.BBWPosition))
+		}
+		if f.MinOp != "" {
+			attrs = append(attrs, fmt.Sprintf("rejects %v %v", f.MinOp, f.Min))
+		}
+		if f.MaxOp != "" {
+			attrs = append(attrs, fmt.Sprintf("rejects %v %v", f.MaxOp, f.Max))
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(buf, " (%v)", strings.Join(attrs, ", "))
+		}
+		buf.WriteString("\n")
+	}
+}
+
+func writeEnum(buf *bytes.Buffer, e d2protocolparser.Enum) {
+	fmt.Fprintf(buf, "enum %v\n", e.Name)
+	for _, v := range e.Values {
+		fmt.Fprintf(buf, "  %v = %v\n", v.Name, v.Value)
+	}
+}
+
+// writeYAML writes the protojson document of p as YAML
+func writeYAML(w io.Writer, p *d2protocolparser.Protocol) error {
+	data, err := protojson.Marshal(p)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err = d.Decode(&v); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	writeYAMLValue(&buf, v, 0)
+	_, err = buf.WriteTo(w)
+	return err
+}
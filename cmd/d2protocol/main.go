@@ -0,0 +1,200 @@
+// Command d2protocol extracts the Dofus 2 network protocol from
+// DofusInvoker.swf.
+//
+// Usage:
+//
+//	d2protocol dump [-format json|yaml|text] <protocol>
+//	d2protocol version <protocol>
+//	d2protocol verify <protocol>
+//	d2protocol diff [-json] <old protocol> <new protocol>
+//	d2protocol gen [-o dir] [-package name] <language> <protocol>
+//	d2protocol lookup <protocol> <id|name>
+//	d2protocol audit <swf>
+//
+// A protocol is either a DofusInvoker.swf file, a JSON dump ending in .json
+// or - to read a swf from the standard input. audit only accepts a swf.
+//
+// verify lists every extraction, verification and serialize/deserialize
+// mismatch problem of a swf, and runs Verify on a JSON dump.
+//
+// The exit status is 0 on success, 1 when verify fails, diff finds changes,
+// lookup finds nothing or audit finds unmatched sequences, and 2 on usage or
+// build errors.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/kelvyne/d2protocolparser"
+	"github.com/kelvyne/d2protocolparser/protojson"
+)
+
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitError   = 2
+)
+
+type command struct {
+	usage string
+	run   func(args []string, stdout io.Writer) (int, error)
+}
+
+var commands = map[string]command{
+	"dump":    {"dump [-format json|yaml|text] <protocol>", runDump},
+	"version": {"version <protocol>", runVersion},
+	"verify":  {"verify <protocol>", runVerify},
+	"diff":    {"diff [-json] <old protocol> <new protocol>", runDiff},
+	"gen":     {"gen [-o dir] [-package name] <language> <protocol>", runGen},
+	"lookup":  {"lookup <protocol> <id|name>", runLookup},
+	"audit":   {"audit <swf>", runAudit},
+}
+
+var commandOrder = []string{"dump", "version", "verify", "diff", "gen", "lookup", "audit"}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage:")
+	for _, name := range commandOrder {
+		fmt.Fprintf(w, "  d2protocol %v\n", commands[name].usage)
+	}
+}
+
+// errUsage is returned by commands called with invalid arguments
+type errUsage string
+
+func (e errUsage) Error() string {
+	return string(e)
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitError
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "d2protocol: unknown command %v\n", args[0])
+		usage(stderr)
+		return exitError
+	}
+	code, err := cmd.run(args[1:], stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "d2protocol %v: %v\n", args[0], err)
+		if _, ok := err.(errUsage); ok {
+			fmt.Fprintf(stderr, "usage: d2protocol %v\n", cmd.usage)
+		}
+		return exitError
+	}
+	return code
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string, nArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage(err.Error())
+	}
+	if fs.NArg() != nArgs {
+		return errUsage(fmt.Sprintf("expected %v arguments, got %v", nArgs, fs.NArg()))
+	}
+	return nil
+}
+
+// openSwf opens a swf file, or the standard input for -
+func openSwf(path string) (io.ReadCloser, error) {
+	if strings.HasSuffix(path, ".json") {
+		return nil, errUsage("a swf file is needed")
+	}
+	if path == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+// loadProtocol builds the protocol from a swf, or reads it from a JSON dump
+func loadProtocol(path string, opts d2protocolparser.Options) (*d2protocolparser.Protocol, error) {
+	if path == "-" {
+		return d2protocolparser.BuildFromReader(os.Stdin, opts)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.HasSuffix(path, ".json") {
+		return protojson.Decode(f)
+	}
+	return d2protocolparser.BuildFromReader(f, opts)
+}
+
+func runVersion(args []string, stdout io.Writer) (int, error) {
+	fs := newFlagSet("version")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return exitError, err
+	}
+	p, err := loadProtocol(fs.Arg(0), d2protocolparser.Options{
+		SkipVerify: true, SkipMessages: true, SkipTypes: true, SkipEnums: true,
+	})
+	if err != nil {
+		return exitError, err
+	}
+	fmt.Fprintln(stdout, versionString(p.Version))
+	return exitOK, nil
+}
+
+func runVerify(args []string, stdout io.Writer) (int, error) {
+	fs := newFlagSet("verify")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return exitError, err
+	}
+	path := fs.Arg(0)
+	if !strings.HasSuffix(path, ".json") {
+		return verifySwf(path, stdout)
+	}
+	p, err := loadProtocol(path, d2protocolparser.Options{SkipVerify: true})
+	if err != nil {
+		return exitError, err
+	}
+	if err = d2protocolparser.Verify(p); err != nil {
+		fmt.Fprintln(stdout, err)
+		return exitFailure, nil
+	}
+	fmt.Fprintf(stdout, "ok: %v messages, %v types, %v enums\n", len(p.Messages), len(p.Types), len(p.Enums))
+	return exitOK, nil
+}
+
+// verifySwf builds a swf in diagnostics mode and lists every problem found
+func verifySwf(path string, stdout io.Writer) (int, error) {
+	r, err := openSwf(path)
+	if err != nil {
+		return exitError, err
+	}
+	defer r.Close()
+
+	p, diags, err := d2protocolparser.BuildWithDiagnostics(r, d2protocolparser.Options{FatalWarnings: true})
+	if err != nil && !errors.Is(err, d2protocolparser.ErrFatalDiagnostics) {
+		return exitError, err
+	}
+	for _, d := range diags {
+		fmt.Fprintln(stdout, d)
+	}
+	if len(diags) != 0 {
+		return exitFailure, nil
+	}
+	fmt.Fprintf(stdout, "ok: %v swf, %v messages, %v types, %v enums\n", p.Container, len(p.Messages), len(p.Types), len(p.Enums))
+	return exitOK, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kelvyne/d2protocolparser"
+	"github.com/kelvyne/d2protocolparser/protojson"
+)
+
+func writeProtocol(t *testing.T, dir, name string, p *d2protocolparser.Protocol) string {
+	data, err := protojson.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err = ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "d2protocol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := &d2protocolparser.Protocol{
+		Messages: []d2protocolparser.Class{
+			{Name: "HelloGameMessage", ProtocolID: 101, Fields: []d2protocolparser.Field{
+				{Name: "lang", Type: "string", WriteMethod: "writeUTF", Method: "String"},
+			}},
+		},
+		Enums:   []d2protocolparser.Enum{{Name: "AlignmentSideEnum", Values: []d2protocolparser.EnumValue{{Name: "ALIGNMENT_UNKNOWN", Value: d2protocolparser.Value{Type: "int32", Int: -2}}}}},
+		Version: d2protocolparser.Version{Major: 2, Minor: 42, Revision: 1027565},
+	}
+	old := writeProtocol(t, dir, "old.json", p)
+	p.Messages[0].ProtocolID = 102
+	cur := writeProtocol(t, dir, "new.json", p)
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+		want string
+	}{
+		{"no command", nil, exitError, ""},
+		{"unknown command", []string{"nope"}, exitError, ""},
+		{"missing argument", []string{"dump"}, exitError, ""},
+		{"version", []string{"version", cur}, exitOK, "2.42.0.1027565.0\n"},
+		{"verify", []string{"verify", cur}, exitOK, "ok: 1 messages, 0 types, 1 enums\n"},
+		{"dump json", []string{"dump", cur}, exitOK, `"protocolId": 102`},
+		{"dump yaml", []string{"dump", "-format", "yaml", cur}, exitOK, "messages:\n  - boxes: []\n    direction: \"unknown\"\n    fields:\n      - bbwPosition: 0\n"},
+		{"dump text", []string{"dump", "-format", "text", cur}, exitOK, "message HelloGameMessage (102)\n"},
+		{"dump unknown format", []string{"dump", "-format", "xml", cur}, exitError, ""},
+		{"lookup id", []string{"lookup", cur, "102"}, exitOK, "  lang string (writeUTF)\n"},
+		{"lookup enum", []string{"lookup", cur, "AlignmentSideEnum"}, exitOK, "ALIGNMENT_UNKNOWN = -2"},
+		{"lookup missing", []string{"lookup", cur, "101"}, exitFailure, "101 not found\n"},
+		{"audit json", []string{"audit", cur}, exitError, ""},
+		{"diff identical", []string{"diff", cur, cur}, exitOK, "0 changes"},
+		{"diff changed", []string{"diff", old, cur}, exitFailure, "protocol-id-changed 101 -> 102"},
+		{"diff json", []string{"diff", "-json", old, cur}, exitFailure, `"kind": "protocol-id-changed"`},
+		{"gen unknown language", []string{"gen", "rust", cur}, exitError, ""},
+		{"gen", []string{"gen", "-o", filepath.Join(dir, "gen"), "go", cur}, exitOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, &stdout, &stderr); code != tt.code {
+				t.Errorf("run() = %v, want %v (stderr: %v)", code, tt.code, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.want) {
+				t.Errorf("run() stdout = %q, want %q", stdout.String(), tt.want)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "gen", "messages.go")); err != nil {
+		t.Errorf("gen did not write messages.go: %v", err)
+	}
+}
+
+func TestWriteText_Container(t *testing.T) {
+	p := &d2protocolparser.Protocol{Version: d2protocolparser.Version{Major: 2, Minor: 42}, Container: d2protocolparser.ContainerCWS}
+	var buf bytes.Buffer
+	if err := writeText(&buf, p); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Dofus 2.42.0.0.0 (zlib-compressed CWS swf)\n"; buf.String() != want {
+		t.Errorf("writeText() = %q, want %q", buf.String(), want)
+	}
+}
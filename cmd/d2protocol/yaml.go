@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// writeYAMLValue writes a value decoded from JSON as a YAML block indented by
+// indent levels. JSON strings are valid YAML double-quoted scalars.
+func writeYAMLValue(buf *bytes.Buffer, v interface{}, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch x := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			buf.WriteString(pad + k + ":")
+			if isYAMLBlock(x[k]) {
+				buf.WriteString("\n")
+				writeYAMLValue(buf, x[k], indent+1)
+			} else {
+				buf.WriteString(" " + yamlScalar(x[k]) + "\n")
+			}
+		}
+	case []interface{}:
+		for _, e := range x {
+			if !isYAMLBlock(e) {
+				buf.WriteString(pad + "- " + yamlScalar(e) + "\n")
+				continue
+			}
+			// the first line of the item block goes after the dash
+			var item bytes.Buffer
+			writeYAMLValue(&item, e, indent+1)
+			buf.WriteString(pad + "- ")
+			buf.Write(item.Bytes()[len(pad)+2:])
+		}
+	default:
+		buf.WriteString(pad + yamlScalar(v) + "\n")
+	}
+}
+
+// isYAMLBlock tells whether v is a non-empty mapping or sequence
+func isYAMLBlock(v interface{}) bool {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		return len(x) > 0
+	case []interface{}:
+		return len(x) > 0
+	}
+	return false
+}
+
+func yamlScalar(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "{}"
+	case []interface{}:
+		return "[]"
+	case bool:
+		return strconv.FormatBool(x)
+	case json.Number:
+		return x.String()
+	case string:
+		return strconv.Quote(x)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,150 @@
+// Package codec decodes and encodes Dofus 2 messages at runtime using the classes
+// described by a d2protocolparser.Protocol
+package codec
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kelvyne/d2protocolparser"
+)
+
+// ErrUnknownMessage means that no message has the requested protocol id
+var ErrUnknownMessage = errors.New("unknown message id")
+
+// ErrUnknownClass means that a class referenced by a field or a parent is
+// not part of the Protocol
+var ErrUnknownClass = errors.New("unknown class")
+
+// ErrUnknownTypeID means that a TypeManager type id does not match any type
+var ErrUnknownTypeID = errors.New("unknown type id")
+
+// ErrTypeNotAllowed means that a TypeManager value is neither the declared
+// type of its field nor one of its subclasses
+var ErrTypeNotAllowed = errors.New("type not allowed for the field")
+
+// ErrTrailingData means that bytes remain after a message has been decoded
+var ErrTrailingData = errors.New("trailing data after message")
+
+// ErrOutOfRange means that a value is rejected by the range checks of the
+// game deserialize method
+var ErrOutOfRange = errors.New("value out of range")
+
+// ErrUnsupportedHash means that a message is hashed by a procedure Encode
+// cannot reproduce, such as a hash appended after the packet
+var ErrUnsupportedHash = errors.New("unsupported hash procedure")
+
+// Object is a dynamic instance of a Protocol class.
+//
+// Fields maps field names, including inherited ones, to their values:
+// scalar fields hold the Go type named by Field.Type (int16, string, ...),
+// class fields hold an *Object and vectors hold a []interface{}.
+type Object struct {
+	Class  string
+	Fields map[string]interface{}
+}
+
+// Hasher returns the hash appended to the payload of a hashed message, as
+// the HASH_FUNCTION set by the client at runtime does. payload holds the
+// serialized message.
+type Hasher func(class *d2protocolparser.Class, payload []byte) ([]byte, error)
+
+// Codec decodes and encodes messages of a Protocol
+type Codec struct {
+	classes  map[string]*d2protocolparser.Class
+	messages map[uint16]*d2protocolparser.Class
+	types    map[uint16]*d2protocolparser.Class
+	hasher   Hasher
+}
+
+// New indexes the classes of p. p must not be modified while the Codec is used.
+func New(p *d2protocolparser.Protocol) *Codec {
+	c := &Codec{
+		classes:  map[string]*d2protocolparser.Class{},
+		messages: map[uint16]*d2protocolparser.Class{},
+		types:    map[uint16]*d2protocolparser.Class{},
+	}
+	for i := range p.Messages {
+		m := &p.Messages[i]
+		c.classes[m.Name] = m
+		c.messages[m.ProtocolID] = m
+	}
+	for i := range p.Types {
+		t := &p.Types[i]
+		c.classes[t.Name] = t
+		c.types[t.ProtocolID] = t
+	}
+	return c
+}
+
+// SetHasher sets the function Encode uses to hash the messages whose
+// UseHashFunc is set. Without a Hasher they are encoded without hash.
+func (c *Codec) SetHasher(h Hasher) {
+	c.hasher = h
+}
+
+// Decode decodes the payload of the message with the given protocol id
+func Decode(p *d2protocolparser.Protocol, id uint16, data []byte) (*Object, error) {
+	return New(p).Decode(id, data)
+}
+
+// checkRange checks a scalar value against the constraints of its field
+func checkRange(f d2protocolparser.Field, v interface{}) error {
+	if f.MinOp == "" && f.MaxOp == "" {
+		return nil
+	}
+	var x float64
+	switch n := v.(type) {
+	case int:
+		x = float64(n)
+	case int8:
+		x = float64(n)
+	case int16:
+		x = float64(n)
+	case int32:
+		x = float64(n)
+	case int64:
+		x = float64(n)
+	case uint:
+		x = float64(n)
+	case uint8:
+		x = float64(n)
+	case uint16:
+		x = float64(n)
+	case uint32:
+		x = float64(n)
+	case uint64:
+		x = float64(n)
+	case float32:
+		x = float64(n)
+	case float64:
+		x = n
+	default:
+		return nil
+	}
+	if !f.InRange(x) {
+		return fmt.Errorf("%w: %v", ErrOutOfRange, v)
+	}
+	return nil
+}
+
+func (c *Codec) class(name string) (*d2protocolparser.Class, error) {
+	class, ok := c.classes[name]
+	if !ok {
+		return nil, fmt.Errorf("%v: %w", name, ErrUnknownClass)
+	}
+	return class, nil
+}
+
+// checkAllowed checks that class can be held by the TypeManager field f, see
+// Protocol.AllowedTypes
+func (c *Codec) checkAllowed(f d2protocolparser.Field, class *d2protocolparser.Class) error {
+	seen := map[string]bool{}
+	for cur := class; cur != nil && !seen[cur.Name]; cur = c.classes[cur.Parent] {
+		if cur.Name == f.Type {
+			return nil
+		}
+		seen[cur.Name] = true
+	}
+	return fmt.Errorf("%w: %v is not a %v", ErrTypeNotAllowed, class.Name, f.Type)
+}
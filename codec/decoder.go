@@ -0,0 +1,187 @@
+package codec
+
+import (
+	"fmt"
+
+	"github.com/kelvyne/d2protocolparser"
+	"github.com/kelvyne/d2protocolparser/wire"
+)
+
+var scalarReaders = map[string]func(*wire.Reader) (interface{}, error){
+	"Boolean":   func(r *wire.Reader) (interface{}, error) { return r.ReadBoolean() },
+	"Int8":      func(r *wire.Reader) (interface{}, error) { return r.ReadInt8() },
+	"UInt8":     func(r *wire.Reader) (interface{}, error) { return r.ReadUInt8() },
+	"Int16":     func(r *wire.Reader) (interface{}, error) { return r.ReadInt16() },
+	"UInt16":    func(r *wire.Reader) (interface{}, error) { return r.ReadUInt16() },
+	"Int32":     func(r *wire.Reader) (interface{}, error) { return r.ReadInt32() },
+	"UInt32":    func(r *wire.Reader) (interface{}, error) { return r.ReadUInt32() },
+	"Float":     func(r *wire.Reader) (interface{}, error) { return r.ReadFloat() },
+	"Double":    func(r *wire.Reader) (interface{}, error) { return r.ReadDouble() },
+	"String":    func(r *wire.Reader) (interface{}, error) { return r.ReadString() },
+	"VarInt16":  func(r *wire.Reader) (interface{}, error) { return r.ReadVarInt16() },
+	"VarUInt16": func(r *wire.Reader) (interface{}, error) { return r.ReadVarUInt16() },
+	"VarInt32":  func(r *wire.Reader) (interface{}, error) { return r.ReadVarInt32() },
+	"VarUInt32": func(r *wire.Reader) (interface{}, error) { return r.ReadVarUInt32() },
+	"VarInt64":  func(r *wire.Reader) (interface{}, error) { return r.ReadVarInt64() },
+	"VarUInt64": func(r *wire.Reader) (interface{}, error) { return r.ReadVarUInt64() },
+}
+
+func readLength(r *wire.Reader, writeMethod string) (uint32, error) {
+	switch writeMethod {
+	case "writeByte":
+		v, err := r.ReadUInt8()
+		return uint32(v), err
+	case "writeShort":
+		v, err := r.ReadUInt16()
+		return uint32(v), err
+	case "writeVarShort":
+		v, err := r.ReadVarUInt16()
+		return uint32(v), err
+	case "writeInt", "writeUnsignedInt":
+		return r.ReadUInt32()
+	case "writeVarInt":
+		return r.ReadVarUInt32()
+	}
+	return 0, fmt.Errorf("unsupported length method %v", writeMethod)
+}
+
+// Decode decodes the payload of the message with the given protocol id
+func (c *Codec) Decode(id uint16, data []byte) (*Object, error) {
+	class, ok := c.messages[id]
+	if !ok {
+		return nil, fmt.Errorf("%v: %w", id, ErrUnknownMessage)
+	}
+	r := wire.NewReader(data)
+	o, err := c.decodeObject(class, r)
+	if err != nil {
+		return nil, err
+	}
+	// the hash appended by HASH_FUNCTION is not described by the class
+	if r.Len() != 0 && !class.UseHashFunc {
+		return nil, fmt.Errorf("%v: %w (%v bytes)", class.Name, ErrTrailingData, r.Len())
+	}
+	return o, nil
+}
+
+// DecodeClass decodes an instance of the named message or type from r
+func (c *Codec) DecodeClass(name string, r *wire.Reader) (*Object, error) {
+	class, err := c.class(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.decodeObject(class, r)
+}
+
+func (c *Codec) decodeObject(class *d2protocolparser.Class, r *wire.Reader) (*Object, error) {
+	o := &Object{Class: class.Name, Fields: map[string]interface{}{}}
+	if err := c.decodeFields(class, r, o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (c *Codec) decodeFields(class *d2protocolparser.Class, r *wire.Reader, o *Object) error {
+	// serializeAs_ starts with super.serializeAs_
+	if class.Parent != "" {
+		parent, err := c.class(class.Parent)
+		if err != nil {
+			return fmt.Errorf("%v: parent %w", class.Name, err)
+		}
+		if err = c.decodeFields(parent, r, o); err != nil {
+			return err
+		}
+	}
+
+	groups := class.BBWGroups()
+	for i, f := range class.Fields {
+		if f.UseBBW {
+			if len(groups) > 0 && groups[0].Index == i {
+				if err := decodeBBW(class, groups[0], r, o); err != nil {
+					return err
+				}
+				groups = groups[1:]
+			}
+			continue
+		}
+		v, err := c.decodeField(f, r)
+		if err != nil {
+			return fmt.Errorf("%v.%v: %w", class.Name, f.Name, err)
+		}
+		o.Fields[f.Name] = v
+	}
+	return nil
+}
+
+func decodeBBW(class *d2protocolparser.Class, g d2protocolparser.BBWGroup, r *wire.Reader, o *Object) error {
+	box, err := r.ReadUInt8()
+	if err != nil {
+		return fmt.Errorf("%v.%v: %w", class.Name, g.Members[0].Field, err)
+	}
+	for _, m := range g.Members {
+		o.Fields[m.Field] = box&(1<<m.Bit) != 0
+	}
+	return nil
+}
+
+func (c *Codec) decodeField(f d2protocolparser.Field, r *wire.Reader) (interface{}, error) {
+	if !f.IsVector {
+		return c.decodeValue(f, r)
+	}
+
+	n := f.Length
+	if f.IsDynamicLength {
+		var err error
+		if n, err = readLength(r, f.WriteLengthMethod); err != nil {
+			return nil, err
+		}
+	}
+	// do not trust the length for the allocation, each element is at least one byte
+	capacity := int(n)
+	if capacity > r.Len() {
+		capacity = r.Len()
+	}
+	values := make([]interface{}, 0, capacity)
+	for i := uint32(0); i < n; i++ {
+		v, err := c.decodeValue(f, r)
+		if err != nil {
+			return nil, fmt.Errorf("[%v]: %w", i, err)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (c *Codec) decodeValue(f d2protocolparser.Field, r *wire.Reader) (interface{}, error) {
+	if f.Method != "" {
+		read, ok := scalarReaders[f.Method]
+		if !ok {
+			return nil, fmt.Errorf("unsupported method %v", f.Method)
+		}
+		v, err := read(r)
+		if err != nil {
+			return nil, err
+		}
+		return v, checkRange(f, v)
+	}
+
+	if !f.UseTypeManager {
+		class, err := c.class(f.Type)
+		if err != nil {
+			return nil, err
+		}
+		return c.decodeObject(class, r)
+	}
+
+	id, err := r.ReadUInt16()
+	if err != nil {
+		return nil, err
+	}
+	class, ok := c.types[id]
+	if !ok {
+		return nil, fmt.Errorf("%v: %w", id, ErrUnknownTypeID)
+	}
+	if err := c.checkAllowed(f, class); err != nil {
+		return nil, err
+	}
+	return c.decodeObject(class, r)
+}
@@ -0,0 +1,157 @@
+package codec
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/kelvyne/d2protocolparser"
+)
+
+func testProtocol() *d2protocolparser.Protocol {
+	return &d2protocolparser.Protocol{
+		Messages: []d2protocolparser.Class{
+			{
+				Name:       "ActorsMessage",
+				ProtocolID: 6000,
+				Fields: []d2protocolparser.Field{
+					{Name: "actors", Type: "ActorInformations", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeShort", UseTypeManager: true},
+					{Name: "ids", Type: "uint32", WriteMethod: "writeVarInt", Method: "VarUInt32", IsVector: true, Length: 2},
+				},
+			},
+			{
+				Name:       "ParentMessage",
+				ProtocolID: 6001,
+				Fields: []d2protocolparser.Field{
+					{Name: "x", Type: "int8", WriteMethod: "writeByte", Method: "Int8"},
+				},
+			},
+			{
+				Name:        "ChildMessage",
+				Parent:      "ParentMessage",
+				ProtocolID:  6002,
+				UseHashFunc: true,
+				Hash:        d2protocolparser.Hash{Function: "HASH_FUNCTION", Payload: true, Position: d2protocolparser.HashPositionPayload},
+				Fields: []d2protocolparser.Field{
+					{Name: "a", Type: "bool", UseBBW: true, BBWPosition: 0},
+					{Name: "b", Type: "bool", UseBBW: true, BBWPosition: 1},
+					{Name: "label", Type: "string", WriteMethod: "writeUTF", Method: "String"},
+				},
+			},
+			{
+				Name:       "CharacterLevelUpMessage",
+				ProtocolID: 6003,
+				Fields: []d2protocolparser.Field{
+					{Name: "newLevel", Type: "uint8", WriteMethod: "writeByte", Method: "UInt8", Min: 1, MinOp: "<", Max: 200, MaxOp: ">"},
+				},
+			},
+		},
+		Types: []d2protocolparser.Class{
+			{
+				Name:       "EntityLook",
+				ProtocolID: 55,
+				Fields: []d2protocolparser.Field{
+					{Name: "bonesId", Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16"},
+				},
+			},
+			{
+				Name:       "ActorInformations",
+				ProtocolID: 150,
+				Fields: []d2protocolparser.Field{
+					{Name: "contextualId", Type: "float64", WriteMethod: "writeDouble", Method: "Double"},
+					{Name: "look", Type: "EntityLook"},
+				},
+			},
+			{
+				Name:       "CharacterInformations",
+				Parent:     "ActorInformations",
+				ProtocolID: 151,
+				Fields: []d2protocolparser.Field{
+					{Name: "sex", Type: "bool", UseBBW: true, BBWPosition: 0},
+					{Name: "dead", Type: "bool", UseBBW: true, BBWPosition: 1},
+					{Name: "name", Type: "string", WriteMethod: "writeUTF", Method: "String"},
+				},
+			},
+		},
+	}
+}
+
+var actorsMessage = []byte{
+	0x00, 0x01, // actors length
+	0x00, 0x97, // CharacterInformations type id
+	0x3F, 0xF0, 0, 0, 0, 0, 0, 0, // contextualId
+	0x01,                 // look.bonesId
+	0x01,                 // sex, dead
+	0x00, 0x02, 'a', 'b', // name
+	0x05, 0xAC, 0x02, // ids
+}
+
+var childMessage = []byte{
+	0xF6,            // x
+	0x02,            // a, b
+	0x00, 0x01, 'z', // label
+	0xDE, 0xAD, 0xBE, 0xEF, // hash
+}
+
+func TestCodec_Decode(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      uint16
+		data    []byte
+		want    *Object
+		wantErr error
+	}{
+		{
+			"typeManager",
+			6000,
+			actorsMessage,
+			&Object{"ActorsMessage", map[string]interface{}{
+				"actors": []interface{}{
+					&Object{"CharacterInformations", map[string]interface{}{
+						"contextualId": float64(1),
+						"look":         &Object{"EntityLook", map[string]interface{}{"bonesId": uint16(1)}},
+						"sex":          true,
+						"dead":         false,
+						"name":         "ab",
+					}},
+				},
+				"ids": []interface{}{uint32(5), uint32(300)},
+			}},
+			nil,
+		},
+		{
+			"parent",
+			6002,
+			childMessage,
+			&Object{"ChildMessage", map[string]interface{}{
+				"x":     int8(-10),
+				"a":     false,
+				"b":     true,
+				"label": "z",
+			}},
+			nil,
+		},
+		{"unknown message", 1, nil, nil, ErrUnknownMessage},
+		{"truncated", 6000, actorsMessage[:10], nil, io.ErrUnexpectedEOF},
+		{"trailing data", 6001, []byte{0x01, 0x02}, nil, ErrTrailingData},
+		{"unknown type id", 6000, []byte{0x00, 0x01, 0x00, 0x01}, nil, ErrUnknownTypeID},
+		{"type not allowed", 6000, []byte{0x00, 0x01, 0x00, 0x37, 0x01, 0x00, 0x00}, nil, ErrTypeNotAllowed},
+		{"in range", 6003, []byte{0xC8}, &Object{"CharacterLevelUpMessage", map[string]interface{}{"newLevel": uint8(200)}}, nil},
+		{"below min", 6003, []byte{0x00}, nil, ErrOutOfRange},
+		{"above max", 6003, []byte{0xC9}, nil, ErrOutOfRange},
+	}
+	c := New(testProtocol())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Decode(tt.id, tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Codec.Decode() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Codec.Decode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
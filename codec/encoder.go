@@ -0,0 +1,344 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/kelvyne/d2protocolparser"
+	"github.com/kelvyne/d2protocolparser/wire"
+)
+
+// ErrMissingField means that an Object does not hold a value for a field
+var ErrMissingField = errors.New("missing field")
+
+// ErrInvalidValue means that a value cannot be encoded with its field method
+var ErrInvalidValue = errors.New("invalid value")
+
+func toInt64(v interface{}, min, max int64) (int64, error) {
+	var n int64
+	switch x := v.(type) {
+	case int:
+		n = int64(x)
+	case int8:
+		n = int64(x)
+	case int16:
+		n = int64(x)
+	case int32:
+		n = int64(x)
+	case int64:
+		n = x
+	case uint:
+		n = int64(x)
+	case uint8:
+		n = int64(x)
+	case uint16:
+		n = int64(x)
+	case uint32:
+		n = int64(x)
+	case uint64:
+		if x > math.MaxInt64 {
+			return 0, fmt.Errorf("%w: %v out of range", ErrInvalidValue, x)
+		}
+		n = int64(x)
+	case float32:
+		n = int64(x)
+	case float64:
+		n = int64(x)
+	default:
+		return 0, fmt.Errorf("%w: %T is not a number", ErrInvalidValue, v)
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("%w: %v out of range", ErrInvalidValue, v)
+	}
+	return n, nil
+}
+
+func toUint64(v interface{}, max uint64) (uint64, error) {
+	if x, ok := v.(uint64); ok {
+		if x > max {
+			return 0, fmt.Errorf("%w: %v out of range", ErrInvalidValue, v)
+		}
+		return x, nil
+	}
+	n, err := toInt64(v, 0, math.MaxInt64)
+	if err != nil {
+		return 0, err
+	}
+	if uint64(n) > max {
+		return 0, fmt.Errorf("%w: %v out of range", ErrInvalidValue, v)
+	}
+	return uint64(n), nil
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float32:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	}
+	n, err := toInt64(v, math.MinInt64, math.MaxInt64)
+	return float64(n), err
+}
+
+func writeInt(v interface{}, min, max int64, write func(int64)) error {
+	n, err := toInt64(v, min, max)
+	if err == nil {
+		write(n)
+	}
+	return err
+}
+
+var scalarWriters = map[string]func(*wire.Writer, interface{}) error{
+	"Boolean": func(w *wire.Writer, v interface{}) error {
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("%w: %T is not a bool", ErrInvalidValue, v)
+		}
+		w.WriteBoolean(b)
+		return nil
+	},
+	"String": func(w *wire.Writer, v interface{}) error {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%w: %T is not a string", ErrInvalidValue, v)
+		}
+		return w.WriteString(s)
+	},
+	"Float": func(w *wire.Writer, v interface{}) error {
+		f, err := toFloat64(v)
+		if err == nil {
+			w.WriteFloat(float32(f))
+		}
+		return err
+	},
+	"Double": func(w *wire.Writer, v interface{}) error {
+		f, err := toFloat64(v)
+		if err == nil {
+			w.WriteDouble(f)
+		}
+		return err
+	},
+	"Int8": func(w *wire.Writer, v interface{}) error {
+		return writeInt(v, math.MinInt8, math.MaxInt8, func(n int64) { w.WriteInt8(int8(n)) })
+	},
+	"UInt8": func(w *wire.Writer, v interface{}) error {
+		return writeInt(v, 0, math.MaxUint8, func(n int64) { w.WriteUInt8(uint8(n)) })
+	},
+	"Int16": func(w *wire.Writer, v interface{}) error {
+		return writeInt(v, math.MinInt16, math.MaxInt16, func(n int64) { w.WriteInt16(int16(n)) })
+	},
+	"UInt16": func(w *wire.Writer, v interface{}) error {
+		return writeInt(v, 0, math.MaxUint16, func(n int64) { w.WriteUInt16(uint16(n)) })
+	},
+	"Int32": func(w *wire.Writer, v interface{}) error {
+		return writeInt(v, math.MinInt32, math.MaxInt32, func(n int64) { w.WriteInt32(int32(n)) })
+	},
+	"UInt32": func(w *wire.Writer, v interface{}) error {
+		return writeInt(v, 0, math.MaxUint32, func(n int64) { w.WriteUInt32(uint32(n)) })
+	},
+	"VarInt16": func(w *wire.Writer, v interface{}) error {
+		return writeInt(v, math.MinInt16, math.MaxInt16, func(n int64) { w.WriteVarInt16(int16(n)) })
+	},
+	"VarUInt16": func(w *wire.Writer, v interface{}) error {
+		return writeInt(v, 0, math.MaxUint16, func(n int64) { w.WriteVarUInt16(uint16(n)) })
+	},
+	"VarInt32": func(w *wire.Writer, v interface{}) error {
+		return writeInt(v, math.MinInt32, math.MaxInt32, func(n int64) { w.WriteVarInt32(int32(n)) })
+	},
+	"VarUInt32": func(w *wire.Writer, v interface{}) error {
+		return writeInt(v, 0, math.MaxUint32, func(n int64) { w.WriteVarUInt32(uint32(n)) })
+	},
+	"VarInt64": func(w *wire.Writer, v interface{}) error {
+		return writeInt(v, math.MinInt64, math.MaxInt64, func(n int64) { w.WriteVarInt64(n) })
+	},
+	"VarUInt64": func(w *wire.Writer, v interface{}) error {
+		n, err := toUint64(v, math.MaxUint64)
+		if err == nil {
+			w.WriteVarUInt64(n)
+		}
+		return err
+	},
+}
+
+func writeLength(w *wire.Writer, writeMethod string, n int) error {
+	var max uint64
+	switch writeMethod {
+	case "writeByte":
+		max = math.MaxUint8
+	case "writeShort", "writeVarShort":
+		max = math.MaxUint16
+	case "writeInt", "writeUnsignedInt", "writeVarInt":
+		max = math.MaxUint32
+	default:
+		return fmt.Errorf("unsupported length method %v", writeMethod)
+	}
+	if uint64(n) > max {
+		return fmt.Errorf("%w: %v elements do not fit in %v", ErrInvalidValue, n, writeMethod)
+	}
+	switch writeMethod {
+	case "writeByte":
+		w.WriteUInt8(uint8(n))
+	case "writeShort":
+		w.WriteUInt16(uint16(n))
+	case "writeVarShort":
+		w.WriteVarUInt16(uint16(n))
+	case "writeInt", "writeUnsignedInt":
+		w.WriteUInt32(uint32(n))
+	case "writeVarInt":
+		w.WriteVarUInt32(uint32(n))
+	}
+	return nil
+}
+
+// Encode encodes o, an instance of a message, to its payload
+func Encode(p *d2protocolparser.Protocol, o *Object) ([]byte, error) {
+	return New(p).Encode(o)
+}
+
+// Encode encodes o, an instance of a message, to its payload. The hash of
+// hashed messages is appended when a Hasher is set.
+func (c *Codec) Encode(o *Object) ([]byte, error) {
+	var w wire.Writer
+	if err := c.EncodeClass(o, &w); err != nil {
+		return nil, err
+	}
+	payload := w.Bytes()
+	class := c.classes[o.Class]
+	if c.hasher == nil || !class.UseHashFunc {
+		return payload, nil
+	}
+	if !class.Hash.Payload || class.Hash.Position != d2protocolparser.HashPositionPayload {
+		return nil, fmt.Errorf("%v: %w (%v hash at %v)", class.Name, ErrUnsupportedHash, class.Hash.Function, class.Hash.Position)
+	}
+	hash, err := c.hasher(class, payload)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v: %w", class.Name, class.Hash.Function, err)
+	}
+	return append(payload, hash...), nil
+}
+
+// EncodeClass encodes o, an instance of a message or a type, to w
+func (c *Codec) EncodeClass(o *Object, w *wire.Writer) error {
+	class, err := c.class(o.Class)
+	if err != nil {
+		return err
+	}
+	return c.encodeFields(class, o, w)
+}
+
+func (c *Codec) encodeFields(class *d2protocolparser.Class, o *Object, w *wire.Writer) error {
+	if class.Parent != "" {
+		parent, err := c.class(class.Parent)
+		if err != nil {
+			return fmt.Errorf("%v: parent %w", class.Name, err)
+		}
+		if err = c.encodeFields(parent, o, w); err != nil {
+			return err
+		}
+	}
+
+	groups := class.BBWGroups()
+	for i, f := range class.Fields {
+		if f.UseBBW {
+			if len(groups) > 0 && groups[0].Index == i {
+				if err := encodeBBW(class, groups[0], o, w); err != nil {
+					return err
+				}
+				groups = groups[1:]
+			}
+			continue
+		}
+		v, ok := o.Fields[f.Name]
+		if !ok {
+			return fmt.Errorf("%v.%v: %w", class.Name, f.Name, ErrMissingField)
+		}
+		if err := c.encodeField(f, v, w); err != nil {
+			return fmt.Errorf("%v.%v: %w", class.Name, f.Name, err)
+		}
+	}
+	return nil
+}
+
+func encodeBBW(class *d2protocolparser.Class, g d2protocolparser.BBWGroup, o *Object, w *wire.Writer) error {
+	var box uint8
+	for _, m := range g.Members {
+		v, ok := o.Fields[m.Field]
+		if !ok {
+			return fmt.Errorf("%v.%v: %w", class.Name, m.Field, ErrMissingField)
+		}
+		flag, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("%v.%v: %w: %T is not a bool", class.Name, m.Field, ErrInvalidValue, v)
+		}
+		if flag {
+			box |= 1 << m.Bit
+		}
+	}
+	w.WriteUInt8(box)
+	return nil
+}
+
+func (c *Codec) encodeField(f d2protocolparser.Field, v interface{}, w *wire.Writer) error {
+	if !f.IsVector {
+		return c.encodeValue(f, v, w)
+	}
+
+	values, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("%w: %T is not a vector", ErrInvalidValue, v)
+	}
+	if f.IsDynamicLength {
+		if err := writeLength(w, f.WriteLengthMethod, len(values)); err != nil {
+			return err
+		}
+	} else if uint32(len(values)) != f.Length {
+		return fmt.Errorf("%w: %v elements for a vector of length %v", ErrInvalidValue, len(values), f.Length)
+	}
+	for i, e := range values {
+		if err := c.encodeValue(f, e, w); err != nil {
+			return fmt.Errorf("[%v]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (c *Codec) encodeValue(f d2protocolparser.Field, v interface{}, w *wire.Writer) error {
+	if f.Method != "" {
+		write, ok := scalarWriters[f.Method]
+		if !ok {
+			return fmt.Errorf("unsupported method %v", f.Method)
+		}
+		if err := checkRange(f, v); err != nil {
+			return err
+		}
+		return write(w, v)
+	}
+
+	o, ok := v.(*Object)
+	if !ok {
+		return fmt.Errorf("%w: %T is not an *Object", ErrInvalidValue, v)
+	}
+
+	if !f.UseTypeManager {
+		class, err := c.class(f.Type)
+		if err != nil {
+			return err
+		}
+		if o.Class != "" && o.Class != class.Name {
+			return fmt.Errorf("%w: %v instead of %v", ErrInvalidValue, o.Class, class.Name)
+		}
+		return c.encodeFields(class, o, w)
+	}
+
+	class, err := c.class(o.Class)
+	if err != nil {
+		return err
+	}
+	if err = c.checkAllowed(f, class); err != nil {
+		return err
+	}
+	w.WriteUInt16(class.ProtocolID)
+	return c.encodeFields(class, o, w)
+}
@@ -0,0 +1,173 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/kelvyne/d2protocolparser"
+)
+
+func TestCodec_Encode_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		id   uint16
+		data []byte
+	}{
+		{"typeManager", 6000, actorsMessage},
+		// the hash is not part of the decoded object
+		{"parent", 6002, childMessage[:len(childMessage)-4]},
+	}
+	c := New(testProtocol())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := c.Decode(tt.id, tt.data)
+			if err != nil {
+				t.Fatalf("Codec.Decode() error = %v", err)
+			}
+			got, err := c.Encode(o)
+			if err != nil {
+				t.Fatalf("Codec.Encode() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("Codec.Encode() = %x, want %x", got, tt.data)
+			}
+		})
+	}
+}
+
+func TestCodec_Encode(t *testing.T) {
+	tests := []struct {
+		name    string
+		o       *Object
+		want    []byte
+		wantErr error
+	}{
+		{
+			"untyped numbers",
+			&Object{"ParentMessage", map[string]interface{}{"x": -10}},
+			[]byte{0xF6},
+			nil,
+		},
+		{
+			"out of range",
+			&Object{"ParentMessage", map[string]interface{}{"x": 200}},
+			nil,
+			ErrInvalidValue,
+		},
+		{
+			"forbidden value",
+			&Object{"CharacterLevelUpMessage", map[string]interface{}{"newLevel": 0}},
+			nil,
+			ErrOutOfRange,
+		},
+		{
+			"allowed value",
+			&Object{"CharacterLevelUpMessage", map[string]interface{}{"newLevel": 1}},
+			[]byte{0x01},
+			nil,
+		},
+		{
+			"missing field",
+			&Object{"ParentMessage", map[string]interface{}{}},
+			nil,
+			ErrMissingField,
+		},
+		{
+			"static length mismatch",
+			&Object{"ActorsMessage", map[string]interface{}{
+				"actors": []interface{}{},
+				"ids":    []interface{}{1},
+			}},
+			nil,
+			ErrInvalidValue,
+		},
+		{
+			"type not allowed",
+			&Object{"ActorsMessage", map[string]interface{}{
+				"actors": []interface{}{&Object{"EntityLook", map[string]interface{}{"bonesId": uint16(1)}}},
+				"ids":    []interface{}{1, 2},
+			}},
+			nil,
+			ErrTypeNotAllowed,
+		},
+		{
+			"unknown class",
+			&Object{"Nope", nil},
+			nil,
+			ErrUnknownClass,
+		},
+	}
+	c := New(testProtocol())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Encode(tt.o)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Codec.Encode() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Codec.Encode() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodec_SetHasher(t *testing.T) {
+	errHash := errors.New("no session key")
+	tests := []struct {
+		name     string
+		position d2protocolparser.HashPosition
+		hasher   Hasher
+		want     []byte
+		wantErr  error
+	}{
+		{"no hasher", d2protocolparser.HashPositionPayload, nil, childMessage[:len(childMessage)-4], nil},
+		{
+			"payload",
+			d2protocolparser.HashPositionPayload,
+			func(class *d2protocolparser.Class, payload []byte) ([]byte, error) {
+				if class.Name != "ChildMessage" || !bytes.Equal(payload, childMessage[:len(childMessage)-4]) {
+					t.Errorf("unexpected hasher arguments %v %x", class.Name, payload)
+				}
+				return []byte{0xDE, 0xAD, 0xBE, 0xEF}, nil
+			},
+			childMessage,
+			nil,
+		},
+		{
+			"hasher error",
+			d2protocolparser.HashPositionPayload,
+			func(*d2protocolparser.Class, []byte) ([]byte, error) { return nil, errHash },
+			nil,
+			errHash,
+		},
+		{
+			"after packet",
+			d2protocolparser.HashPositionPacket,
+			func(*d2protocolparser.Class, []byte) ([]byte, error) { return nil, nil },
+			nil,
+			ErrUnsupportedHash,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testProtocol()
+			child, _ := p.ClassByName("ChildMessage")
+			child.Hash.Position = tt.position
+			c := New(p)
+			c.SetHasher(tt.hasher)
+			o, err := c.Decode(6002, childMessage)
+			if err != nil {
+				t.Fatalf("Codec.Decode() error = %v", err)
+			}
+			got, err := c.Encode(o)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Codec.Encode() error = %v, want %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Codec.Encode() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
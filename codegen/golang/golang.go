@@ -0,0 +1,537 @@
+// Package golang generates a Go package from a d2protocolparser.Protocol.
+//
+// Every message and type becomes a struct embedding its parent, with
+// Serialize and Deserialize methods built on the wire package. Fields holding
+// their own class, directly or not, are pointers. Enumerations become typed
+// constants.
+package golang
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/kelvyne/d2protocolparser"
+)
+
+// Options controls the generated code
+type Options struct {
+	Package string // Package is the generated package name, "protocol" by default
+}
+
+// Generate generates the Go source files for p, keyed by file name
+func Generate(p *d2protocolparser.Protocol, opts Options) (map[string][]byte, error) {
+	if opts.Package == "" {
+		opts.Package = "protocol"
+	}
+	g := newGenerator(p, opts)
+
+	files := map[string]func() error{
+		"protocol.go": g.genProtocol,
+		"enums.go":    g.genEnums,
+		"messages.go": func() error { return g.genClasses(p.Messages, true) },
+		"types.go":    func() error { return g.genClasses(p.Types, false) },
+	}
+	out := map[string][]byte{}
+	for name, gen := range files {
+		g.buf.Reset()
+		g.imports = map[string]bool{}
+		if err := gen(); err != nil {
+			return nil, err
+		}
+		src, err := format.Source(append(g.header(), g.buf.Bytes()...))
+		if err != nil {
+			return nil, fmt.Errorf("%v: generated invalid code: %v", name, err)
+		}
+		out[name] = src
+	}
+	return out, nil
+}
+
+// GenerateDir generates the Go source files for p in dir
+func GenerateDir(p *d2protocolparser.Protocol, dir string, opts Options) error {
+	files, err := Generate(p, opts)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, src := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), src, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type generator struct {
+	p       *d2protocolparser.Protocol
+	opts    Options
+	classes map[string]*d2protocolparser.Class
+	buf     bytes.Buffer
+	imports map[string]bool // imports used by the file being generated
+}
+
+func newGenerator(p *d2protocolparser.Protocol, opts Options) *generator {
+	g := &generator{p: p, opts: opts, classes: map[string]*d2protocolparser.Class{}}
+	for i := range p.Messages {
+		g.classes[p.Messages[i].Name] = &p.Messages[i]
+	}
+	for i := range p.Types {
+		g.classes[p.Types[i].Name] = &p.Types[i]
+	}
+	return g
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) header() []byte {
+	var b bytes.Buffer
+	v := g.p.Version
+	fmt.Fprintf(&b, "// Code generated by d2protocolparser from Dofus %v.%v.%v.%v.%v. DO NOT EDIT.\n\n",
+		v.Major, v.Minor, v.Release, v.Revision, v.Patch)
+	fmt.Fprintf(&b, "package %v\n\n", g.opts.Package)
+	if len(g.imports) == 0 {
+		return b.Bytes()
+	}
+	b.WriteString("import (\n")
+	for _, path := range []string{"fmt", "github.com/kelvyne/d2protocolparser/wire"} {
+		if g.imports[path] {
+			fmt.Fprintf(&b, "%q\n", path)
+		}
+	}
+	b.WriteString(")\n\n")
+	return b.Bytes()
+}
+
+func exportName(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// constName converts the UPPER_SNAKE_CASE enum value at index i to
+// CamelCase. Names made only of underscores become Value followed by i.
+func constName(name string, i int) string {
+	var parts []string
+	for _, p := range strings.Split(name, "_") {
+		if p == "" {
+			continue
+		}
+		parts = append(parts, exportName(strings.ToLower(p)))
+	}
+	if len(parts) == 0 {
+		return "Value" + strconv.Itoa(i)
+	}
+	return strings.Join(parts, "")
+}
+
+func (g *generator) genProtocol() error {
+	g.imports["fmt"] = true
+	g.imports["github.com/kelvyne/d2protocolparser/wire"] = true
+	g.printf(`// Message is implemented by every message
+type Message interface {
+	MessageID() uint16
+	Serialize(w *wire.Writer) error
+	Deserialize(r *wire.Reader) error
+}
+
+// Type is implemented by every type
+type Type interface {
+	TypeID() uint16
+	Serialize(w *wire.Writer) error
+	Deserialize(r *wire.Reader) error
+}
+
+`)
+	if err := g.genFactory("Message", g.p.Messages); err != nil {
+		return err
+	}
+	return g.genFactory("Type", g.p.Types)
+}
+
+func (g *generator) genFactory(kind string, classes []d2protocolparser.Class) error {
+	g.printf("// New%v returns a new %v from its protocol id\n", kind, strings.ToLower(kind))
+	g.printf("func New%v(id uint16) (%v, error) {\nswitch id {\n", kind, kind)
+	seen := map[uint16]string{}
+	for _, c := range classes {
+		if other, ok := seen[c.ProtocolID]; ok {
+			return fmt.Errorf("%v and %v share the protocol id %v", other, c.Name, c.ProtocolID)
+		}
+		seen[c.ProtocolID] = c.Name
+		g.printf("case %v:\nreturn &%v{}, nil\n", c.ProtocolID, c.Name)
+	}
+	g.printf("}\nreturn nil, fmt.Errorf(\"unknown %v id %%v\", id)\n}\n\n", strings.ToLower(kind))
+	return nil
+}
+
+func (g *generator) genEnums() error {
+	for _, e := range g.p.Enums {
+		// enumerations mixing types only get untyped constants, null and
+		// non-finite values have no Go constant
+		typ := e.Type()
+		if typ == "null" {
+			typ = ""
+		}
+		if typ != "" {
+			g.printf("// %v is generated from the %v enumeration\n", e.Name, e.Name)
+			g.printf("type %v %v\n\n", e.Name, typ)
+		}
+		if len(e.Values) == 0 {
+			continue
+		}
+		if typ != "" {
+			g.printf("// %v values\nconst (\n", e.Name)
+		} else {
+			g.printf("// %v values are generated from the %v enumeration\nconst (\n", e.Name, e.Name)
+		}
+		for i, v := range e.Values {
+			switch {
+			case v.Value.Type == "null":
+			case v.Value.Type == "float64" && (math.IsNaN(v.Value.Number) || math.IsInf(v.Value.Number, 0)):
+			case typ != "":
+				g.printf("%v%v %v = %v\n", e.Name, constName(v.Name, i), e.Name, v.Value)
+			default:
+				g.printf("%v%v = %v\n", e.Name, constName(v.Name, i), v.Value)
+			}
+		}
+		g.printf(")\n\n")
+	}
+	return nil
+}
+
+func (g *generator) genClasses(classes []d2protocolparser.Class, isMessage bool) error {
+	for i := range classes {
+		if err := g.genClass(&classes[i], isMessage); err != nil {
+			return fmt.Errorf("%v: %v", classes[i].Name, err)
+		}
+	}
+	return nil
+}
+
+func (g *generator) goFieldName(c *d2protocolparser.Class, f d2protocolparser.Field) string {
+	name := exportName(f.Name)
+	// the embedded parent is a field named after its type
+	if name == c.Parent {
+		name += "Field"
+	}
+	return name
+}
+
+// isClass tells whether f holds a message or type by value
+func isClass(f d2protocolparser.Field) bool {
+	return f.Method == "" && !(f.UseBBW && f.Type == "bool") && !f.UseTypeManager
+}
+
+// contains tells whether the class name holds target by value, through its
+// parents or its fields
+func (g *generator) contains(name, target string, seen map[string]bool) bool {
+	if name == target {
+		return true
+	}
+	c, ok := g.classes[name]
+	if !ok || seen[name] {
+		return false
+	}
+	seen[name] = true
+	if c.Parent != "" && g.contains(c.Parent, target, seen) {
+		return true
+	}
+	for _, f := range c.Fields {
+		if isClass(f) && !f.IsVector && g.contains(f.Type, target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPointer tells whether the field f of c is generated as a pointer, which
+// is the case of the fields holding c by value
+func (g *generator) isPointer(c *d2protocolparser.Class, f d2protocolparser.Field) bool {
+	return isClass(f) && !f.IsVector && g.contains(f.Type, c.Name, map[string]bool{})
+}
+
+func (g *generator) goType(c *d2protocolparser.Class, f d2protocolparser.Field) (string, error) {
+	var t string
+	switch {
+	case f.Method != "" || (f.UseBBW && f.Type == "bool"):
+		t = f.Type
+	case f.UseTypeManager:
+		t = "Type"
+	default:
+		if _, ok := g.classes[f.Type]; !ok {
+			return "", fmt.Errorf("%v: unknown type %v", f.Name, f.Type)
+		}
+		t = f.Type
+		if g.isPointer(c, f) {
+			t = "*" + t
+		}
+	}
+	if f.IsVector {
+		t = "[]" + t
+	}
+	return t, nil
+}
+
+func (g *generator) genClass(c *d2protocolparser.Class, isMessage bool) error {
+	if c.Parent != "" {
+		if _, ok := g.classes[c.Parent]; !ok {
+			return fmt.Errorf("unknown parent %v", c.Parent)
+		}
+	}
+
+	g.printf("// %v is generated from %v.%v\n", c.Name, c.Namespace, c.Name)
+	g.printf("type %v struct {\n", c.Name)
+	if c.Parent != "" {
+		g.printf("%v\n", c.Parent)
+	}
+	for _, f := range c.Fields {
+		t, err := g.goType(c, f)
+		if err != nil {
+			return err
+		}
+		g.printf("%v %v\n", g.goFieldName(c, f), t)
+	}
+	g.printf("}\n\n")
+
+	idMethod := "TypeID"
+	if isMessage {
+		idMethod = "MessageID"
+	}
+	g.printf("// %v returns the protocol id of %v\n", idMethod, c.Name)
+	g.printf("func (*%v) %v() uint16 {\nreturn %v\n}\n\n", c.Name, idMethod, c.ProtocolID)
+
+	if err := g.genSerialize(c); err != nil {
+		return err
+	}
+	return g.genDeserialize(c)
+}
+
+// bbwBoxes indexes the BooleanByteWrapper bytes of a class by the index of
+// their first member
+func bbwBoxes(c *d2protocolparser.Class) map[int]int {
+	boxes := map[int]int{}
+	for i, g := range c.BBWGroups() {
+		boxes[g.Index] = i
+	}
+	return boxes
+}
+
+// bbwFlag returns the Go field name of a BooleanByteWrapper flag
+func (g *generator) bbwFlag(c *d2protocolparser.Class, m d2protocolparser.BBWMember) string {
+	return g.goFieldName(c, d2protocolparser.Field{Name: m.Field})
+}
+
+var lengthMethods = map[string]string{
+	"writeByte":        "UInt8",
+	"writeShort":       "UInt16",
+	"writeVarShort":    "VarUInt16",
+	"writeInt":         "UInt32",
+	"writeUnsignedInt": "UInt32",
+	"writeVarInt":      "VarUInt32",
+}
+
+var lengthTypes = map[string]string{
+	"UInt8":     "uint8",
+	"UInt16":    "uint16",
+	"VarUInt16": "uint16",
+	"UInt32":    "uint32",
+	"VarUInt32": "uint32",
+}
+
+func (g *generator) genSerialize(c *d2protocolparser.Class) error {
+	g.imports["github.com/kelvyne/d2protocolparser/wire"] = true
+	g.printf("// Serialize writes %v to w\n", c.Name)
+	g.printf("func (m *%v) Serialize(w *wire.Writer) error {\n", c.Name)
+	if c.Parent != "" {
+		g.printf("if err := m.%v.Serialize(w); err != nil {\nreturn err\n}\n", c.Parent)
+	}
+	groups := c.BBWGroups()
+	boxes := bbwBoxes(c)
+	for idx, f := range c.Fields {
+		if f.UseBBW {
+			if i, ok := boxes[idx]; ok {
+				g.printf("var box%v uint8\n", i)
+				for _, m := range groups[i].Members {
+					g.printf("if m.%v {\nbox%v |= 1 << %v\n}\n", g.bbwFlag(c, m), i, m.Bit)
+				}
+				g.printf("w.WriteUInt8(box%v)\n", i)
+			}
+			continue
+		}
+		name := "m." + g.goFieldName(c, f)
+		if !f.IsVector {
+			if err := g.genWriteValue(c, f, name); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.IsDynamicLength {
+			m, ok := lengthMethods[f.WriteLengthMethod]
+			if !ok {
+				return fmt.Errorf("%v: unsupported length method %v", f.Name, f.WriteLengthMethod)
+			}
+			g.printf("w.Write%v(%v(len(%v)))\n", m, lengthTypes[m], name)
+		} else {
+			g.imports["fmt"] = true
+			g.printf("if len(%v) != %v {\n", name, f.Length)
+			g.printf("return fmt.Errorf(\"%v.%v: %%v elements instead of %v\", len(%v))\n}\n",
+				c.Name, f.Name, f.Length, name)
+		}
+		g.printf("for _, v := range %v {\n", name)
+		if err := g.genWriteValue(c, f, "v"); err != nil {
+			return err
+		}
+		g.printf("}\n")
+	}
+	g.printf("return nil\n}\n\n")
+	return nil
+}
+
+// intRanges contains the values representable by the integer types
+var intRanges = map[string][2]float64{
+	"int8":   {math.MinInt8, math.MaxInt8},
+	"uint8":  {0, math.MaxUint8},
+	"int16":  {math.MinInt16, math.MaxInt16},
+	"uint16": {0, math.MaxUint16},
+	"int32":  {math.MinInt32, math.MaxInt32},
+	"uint32": {0, math.MaxUint32},
+	"int64":  {math.MinInt64, math.MaxInt64},
+	"uint64": {0, math.MaxUint64},
+}
+
+// rangeConditions returns the conditions rejecting v according to the field
+// constraints. Checks that cannot fail or compile for the Go type are skipped.
+func rangeConditions(f d2protocolparser.Field, v string) []string {
+	r, isInt := intRanges[f.Type]
+	if !isInt && f.Type != "float32" && f.Type != "float64" {
+		return nil
+	}
+	check := func(op string, bound float64, canFail bool) string {
+		if op == "" || (isInt && (bound != math.Trunc(bound) || bound < r[0] || bound > r[1] || !canFail)) {
+			return ""
+		}
+		return fmt.Sprintf("%v %v %v", v, op, strconv.FormatFloat(bound, 'f', -1, 64))
+	}
+	var conds []string
+	if c := check(f.MinOp, f.Min, f.Min > r[0] || (f.MinOp == "<=" && f.Min == r[0])); c != "" {
+		conds = append(conds, c)
+	}
+	if c := check(f.MaxOp, f.Max, f.Max < r[1] || (f.MaxOp == ">=" && f.Max == r[1])); c != "" {
+		conds = append(conds, c)
+	}
+	return conds
+}
+
+func (g *generator) genRangeCheck(c *d2protocolparser.Class, f d2protocolparser.Field, v string) {
+	conds := rangeConditions(f, v)
+	if len(conds) == 0 {
+		return
+	}
+	g.imports["fmt"] = true
+	g.printf("if %v {\n", strings.Join(conds, " || "))
+	g.printf("return fmt.Errorf(\"%v.%v: forbidden value %%v\", %v)\n}\n", c.Name, f.Name, v)
+}
+
+func (g *generator) genWriteValue(c *d2protocolparser.Class, f d2protocolparser.Field, v string) error {
+	switch {
+	case f.Method == "String":
+		g.printf("if err := w.WriteString(%v); err != nil {\nreturn err\n}\n", v)
+	case f.Method != "":
+		g.genRangeCheck(c, f, v)
+		g.printf("w.Write%v(%v)\n", f.Method, v)
+	case f.UseTypeManager:
+		g.printf("w.WriteUInt16(%v.TypeID())\n", v)
+		g.printf("if err := %v.Serialize(w); err != nil {\nreturn err\n}\n", v)
+	default:
+		if g.isPointer(c, f) {
+			g.imports["fmt"] = true
+			g.printf("if %v == nil {\nreturn fmt.Errorf(\"%v.%v: nil value\")\n}\n", v, c.Name, f.Name)
+		}
+		g.printf("if err := %v.Serialize(w); err != nil {\nreturn err\n}\n", v)
+	}
+	return nil
+}
+
+func (g *generator) genDeserialize(c *d2protocolparser.Class) error {
+	g.printf("// Deserialize reads %v from r\n", c.Name)
+	g.printf("func (m *%v) Deserialize(r *wire.Reader) error {\n", c.Name)
+	if c.Parent != "" {
+		g.printf("if err := m.%v.Deserialize(r); err != nil {\nreturn err\n}\n", c.Parent)
+	}
+	groups := c.BBWGroups()
+	boxes := bbwBoxes(c)
+	for idx, f := range c.Fields {
+		if f.UseBBW {
+			if i, ok := boxes[idx]; ok {
+				g.printf("box%v, err := r.ReadUInt8()\nif err != nil {\nreturn err\n}\n", i)
+				for _, m := range groups[i].Members {
+					g.printf("m.%v = box%v&(1<<%v) != 0\n", g.bbwFlag(c, m), i, m.Bit)
+				}
+			}
+			continue
+		}
+		name := "m." + g.goFieldName(c, f)
+		if !f.IsVector {
+			if err := g.genReadValue(c, f, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		g.printf("{\n")
+		if f.IsDynamicLength {
+			m, ok := lengthMethods[f.WriteLengthMethod]
+			if !ok {
+				return fmt.Errorf("%v: unsupported length method %v", f.Name, f.WriteLengthMethod)
+			}
+			g.printf("n, err := r.Read%v()\nif err != nil {\nreturn err\n}\n", m)
+		} else {
+			g.printf("n := %v\n", f.Length)
+		}
+		t, err := g.goType(c, f)
+		if err != nil {
+			return err
+		}
+		g.printf("%v = nil\n", name)
+		g.printf("for i := 0; i < int(n); i++ {\nvar v %v\n", strings.TrimPrefix(t, "[]"))
+		if err := g.genReadValue(c, f, "v"); err != nil {
+			return err
+		}
+		g.printf("%v = append(%v, v)\n}\n}\n", name, name)
+	}
+	g.printf("return nil\n}\n\n")
+	return nil
+}
+
+func (g *generator) genReadValue(c *d2protocolparser.Class, f d2protocolparser.Field, v string) error {
+	switch {
+	case f.Method != "":
+		g.printf("{\nx, err := r.Read%v()\nif err != nil {\nreturn err\n}\n", f.Method)
+		g.genRangeCheck(c, f, "x")
+		g.printf("%v = x\n}\n", v)
+	case f.UseTypeManager:
+		g.printf("{\nid, err := r.ReadUInt16()\nif err != nil {\nreturn err\n}\n")
+		g.printf("t, err := NewType(id)\nif err != nil {\nreturn err\n}\n")
+		g.printf("if err = t.Deserialize(r); err != nil {\nreturn err\n}\n%v = t\n}\n", v)
+	default:
+		if g.isPointer(c, f) {
+			g.printf("%v = new(%v)\n", v, f.Type)
+		}
+		g.printf("if err := %v.Deserialize(r); err != nil {\nreturn err\n}\n", v)
+	}
+	return nil
+}
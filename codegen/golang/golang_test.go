@@ -0,0 +1,221 @@
+package golang
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/kelvyne/d2protocolparser"
+)
+
+func testProtocol() *d2protocolparser.Protocol {
+	return &d2protocolparser.Protocol{
+		Messages: []d2protocolparser.Class{
+			{
+				Name:       "IdentificationMessage",
+				Namespace:  "com.ankamagames.dofus.network.messages.connection",
+				ProtocolID: 4,
+				Fields: []d2protocolparser.Field{
+					{Name: "lang", Type: "string", WriteMethod: "writeUTF", Method: "String"},
+					{Name: "credentials", Type: "int8", WriteMethod: "writeByte", Method: "Int8", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeVarInt"},
+					{Name: "autoconnect", Type: "bool", UseBBW: true, BBWPosition: 0},
+					{Name: "useCertificate", Type: "bool", UseBBW: true, BBWPosition: 1},
+					{Name: "position", Type: "int16", WriteMethod: "writeShort", Method: "Int16", IsVector: true, Length: 2},
+					{Name: "serverId", Type: "int16", WriteMethod: "writeShort", Method: "Int16", MinOp: "<", Min: 0},
+					{Name: "level", Type: "uint8", WriteMethod: "writeByte", Method: "UInt8", MinOp: "<", Min: 0, MaxOp: ">", Max: 200},
+				},
+			},
+			{
+				Name:       "HelloGameMessage",
+				Namespace:  "com.ankamagames.dofus.network.messages.game.approach",
+				ProtocolID: 101,
+			},
+			{
+				Name:       "MapComplementaryInformationsDataMessage",
+				Namespace:  "com.ankamagames.dofus.network.messages.game.context.roleplay",
+				ProtocolID: 226,
+				Fields: []d2protocolparser.Field{
+					{Name: "subAreaId", Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16", MinOp: "<"},
+					{Name: "actors", Type: "GameContextActorInformations", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeShort", UseTypeManager: true},
+					{Name: "dispositions", Type: "EntityDispositionInformations", IsVector: true, Length: 2},
+					{Name: "hasAggressiveMonsters", Type: "bool", WriteMethod: "writeBoolean", Method: "Boolean"},
+				},
+			},
+		},
+		Types: []d2protocolparser.Class{
+			{
+				Name:       "GameContextActorInformations",
+				Namespace:  "com.ankamagames.dofus.network.types.game.context",
+				ProtocolID: 150,
+				Fields: []d2protocolparser.Field{
+					{Name: "contextualId", Type: "float64", WriteMethod: "writeDouble", Method: "Double"},
+					{Name: "disposition", Type: "EntityDispositionInformations", UseTypeManager: true},
+				},
+			},
+			{
+				Name:       "EntityDispositionInformations",
+				Namespace:  "com.ankamagames.dofus.network.types.game.context",
+				ProtocolID: 60,
+				Fields: []d2protocolparser.Field{
+					{Name: "cellId", Type: "int16", WriteMethod: "writeShort", Method: "Int16"},
+				},
+			},
+			{
+				Name:       "FightEntityDispositionInformations",
+				Namespace:  "com.ankamagames.dofus.network.types.game.context",
+				Parent:     "EntityDispositionInformations",
+				ProtocolID: 217,
+				Fields: []d2protocolparser.Field{
+					{Name: "carryingCharacterId", Type: "float64", WriteMethod: "writeDouble", Method: "Double"},
+				},
+			},
+			{
+				Name:       "TreeNodeInformations",
+				Namespace:  "com.ankamagames.dofus.network.types.game.tree",
+				ProtocolID: 1000,
+				Fields: []d2protocolparser.Field{
+					{Name: "next", Type: "TreeNodeInformations"},
+					{Name: "children", Type: "TreeNodeInformations", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeShort"},
+					{Name: "leaf", Type: "TreeLeafInformations"},
+				},
+			},
+			{
+				Name:       "TreeLeafInformations",
+				Namespace:  "com.ankamagames.dofus.network.types.game.tree",
+				ProtocolID: 1001,
+				Fields: []d2protocolparser.Field{
+					{Name: "node", Type: "TreeNodeInformations"},
+					{Name: "disposition", Type: "EntityDispositionInformations"},
+				},
+			},
+		},
+		Enums: []d2protocolparser.Enum{
+			{
+				Name: "AlignmentSideEnum",
+				Values: []d2protocolparser.EnumValue{
+					{Name: "ALIGNMENT_UNKNOWN", Value: d2protocolparser.Value{Type: "int32", Int: -2}},
+					{Name: "ALIGNMENT_NEUTRAL", Value: d2protocolparser.Value{Type: "int32", Int: 0}},
+					{Name: "__", Value: d2protocolparser.Value{Type: "int32", Int: 1}},
+					{Name: "_2", Value: d2protocolparser.Value{Type: "int32", Int: 2}},
+				},
+			},
+			{
+				Name: "BuildTypeEnum",
+				Values: []d2protocolparser.EnumValue{
+					{Name: "RELEASE", Value: d2protocolparser.Value{Type: "string", Text: "release"}},
+				},
+			},
+			{
+				Name: "MixedEnum",
+				Values: []d2protocolparser.EnumValue{
+					{Name: "NAME", Value: d2protocolparser.Value{Type: "string", Text: "a"}},
+					{Name: "COUNT", Value: d2protocolparser.Value{Type: "int32", Int: 2}},
+					{Name: "NONE", Value: d2protocolparser.Value{Type: "null"}},
+				},
+			},
+			{
+				Name: "LimitEnum",
+				Values: []d2protocolparser.EnumValue{
+					{Name: "MAX", Value: d2protocolparser.Value{Type: "float64", Number: math.Inf(1)}},
+					{Name: "MIN", Value: d2protocolparser.Value{Type: "float64", Number: math.Inf(-1)}},
+					{Name: "UNDEFINED", Value: d2protocolparser.Value{Type: "float64", Number: math.NaN()}},
+					{Name: "HALF", Value: d2protocolparser.Value{Type: "float64", Number: 0.5}},
+				},
+			},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	files, err := Generate(testProtocol(), Options{Package: "d2"})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"protocol.go", []string{"package d2", "func NewMessage(id uint16) (Message, error) {", "case 217:\n\t\treturn &FightEntityDispositionInformations{}, nil"}},
+		{"enums.go", []string{
+			"type AlignmentSideEnum int32", "AlignmentSideEnumAlignmentUnknown AlignmentSideEnum = -2",
+			"type BuildTypeEnum string", "BuildTypeEnumRelease BuildTypeEnum = \"release\"",
+			"MixedEnumName  = \"a\"", "MixedEnumCount = 2",
+			"AlignmentSideEnumValue2 ", "AlignmentSideEnum2 ",
+			"const (\n\tLimitEnumHalf LimitEnum = 0.5\n)",
+		}},
+		{"messages.go", []string{"Credentials    []int8", "box0 |= 1 << 1", "w.WriteVarUInt32(uint32(len(m.Credentials)))", "if len(m.Position) != 2 {", "if m.ServerId < 0 {\n\t\treturn fmt.Errorf(\"IdentificationMessage.serverId: forbidden value %v\", m.ServerId)", "if x > 200 {", "func (*HelloGameMessage) MessageID() uint16 {"}},
+		{"types.go", []string{"type FightEntityDispositionInformations struct {\n\tEntityDispositionInformations\n", "w.WriteUInt16(m.Disposition.TypeID())", "t, err := NewType(id)",
+			"Next     *TreeNodeInformations\n\tChildren []TreeNodeInformations\n\tLeaf     *TreeLeafInformations",
+			"Node        *TreeNodeInformations\n\tDisposition EntityDispositionInformations",
+			"if m.Next == nil {\n\t\treturn fmt.Errorf(\"TreeNodeInformations.next: nil value\")",
+			"m.Next = new(TreeNodeInformations)\n\tif err := m.Next.Deserialize(r); err != nil {",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			src, ok := files[tt.file]
+			if !ok {
+				t.Fatalf("%v not generated", tt.file)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(src), want) {
+					t.Errorf("%v does not contain %q", tt.file, want)
+				}
+			}
+		})
+	}
+}
+
+// TestGenerate_TypeCheck checks that the generated package is gofmt-ed and
+// compiles against the wire package
+func TestGenerate_TypeCheck(t *testing.T) {
+	files, err := Generate(testProtocol(), Options{Package: "d2"})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fset := token.NewFileSet()
+	var parsed []*ast.File
+	for _, name := range names {
+		src := files[name]
+		formatted, err := format.Source(src)
+		if err != nil {
+			t.Fatalf("%v: format.Source() error = %v", name, err)
+		}
+		if !bytes.Equal(formatted, src) {
+			t.Errorf("%v is not gofmt-ed", name)
+		}
+		f, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		parsed = append(parsed, f)
+	}
+
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err = conf.Check("d2", fset, parsed, nil); err != nil {
+		t.Errorf("generated package does not compile: %v", err)
+	}
+}
+
+func TestGenerate_UnknownParent(t *testing.T) {
+	p := testProtocol()
+	p.Types[2].Parent = "Missing"
+	if _, err := Generate(p, Options{}); err == nil {
+		t.Errorf("expected error for unknown parent")
+	}
+}
@@ -0,0 +1,104 @@
+package d2protocolparser
+
+import (
+	"github.com/kelvyne/as3"
+	"github.com/kelvyne/as3/bytecode"
+)
+
+// forbiddenOps maps a comparison between a value and a constant to the
+// relation rejected by the deserialize method. The ifnXX instructions jump
+// over the throw when the relation does not hold, the ifXX ones when it does.
+var forbiddenOps = map[string]string{
+	"lessthan":      "<",
+	"ifnlt":         "<",
+	"ifge":          "<",
+	"lessequals":    "<=",
+	"ifnle":         "<=",
+	"ifgt":          "<=",
+	"greaterthan":   ">",
+	"ifngt":         ">",
+	"ifle":          ">",
+	"greaterequals": ">=",
+	"ifnge":         ">=",
+	"iflt":          ">=",
+}
+
+// constraint is a comparison of a field, or of a local holding a vector
+// element, with a constant
+type constraint struct {
+	field string
+	local uint32
+	op    string
+	value float64
+}
+
+func (c constraint) apply(f *Field) {
+	switch c.op {
+	case "<", "<=":
+		f.Min, f.MinOp = c.value, c.op
+	case ">", ">=":
+		f.Max, f.MaxOp = c.value, c.op
+	}
+}
+
+// pushedValue returns the constant pushed by an instruction
+func (b *builder) pushedValue(abc *as3.AbcFile, instr bytecode.Instr) (float64, bool) {
+	if len(instr.Operands) == 0 {
+		return 0, false
+	}
+	op := instr.Operands[0]
+	switch instr.Model.Name {
+	case "pushbyte":
+		return float64(int8(op)), true
+	case "pushshort":
+		return float64(int16(op)), true
+	case "pushint":
+		return float64(abc.Source.ConstantPool.Integers[op]), true
+	case "pushuint":
+		return float64(abc.Source.ConstantPool.UIntegers[op]), true
+	case "pushdouble":
+		return abc.Source.ConstantPool.Doubles[op], true
+	}
+	return 0, false
+}
+
+// constraintAt recognizes a comparison of this.field or of a local with a
+// constant ending at instrs[i], such as
+// getlocal0, getproperty level, pushbyte 0, ifnlt
+func (b *builder) constraintAt(abc *as3.AbcFile, instrs []bytecode.Instr, i int) (constraint, bool) {
+	op, ok := forbiddenOps[instrs[i].Model.Name]
+	if !ok || i < 2 {
+		return constraint{}, false
+	}
+	value, ok := b.pushedValue(abc, instrs[i-1])
+	if !ok {
+		return constraint{}, false
+	}
+	subject := instrs[i-2]
+	if reg, ok := localIndex(subject, "getlocal"); ok {
+		return constraint{local: reg, op: op, value: value}, true
+	}
+	if subject.Model.Name != "getproperty" || i < 3 {
+		return constraint{}, false
+	}
+	if reg, ok := localIndex(instrs[i-3], "getlocal"); !ok || reg != 0 {
+		return constraint{}, false
+	}
+	multiname := abc.Source.ConstantPool.Multinames[subject.Operands[0]]
+	if !isPublicQName(abc, multiname) {
+		return constraint{}, false
+	}
+	field := abc.Source.ConstantPool.Strings[multiname.Name]
+	return constraint{field: field, op: op, value: value}, true
+}
+
+// InRange reports whether v passes the range checks of the deserialize method
+func (f Field) InRange(v float64) bool {
+	switch {
+	case f.MinOp == "<" && v < f.Min, f.MinOp == "<=" && v <= f.Min:
+		return false
+	case f.MaxOp == ">" && v > f.Max, f.MaxOp == ">=" && v >= f.Max:
+		return false
+	}
+	return true
+}
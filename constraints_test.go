@@ -0,0 +1,61 @@
+package d2protocolparser
+
+import (
+	"testing"
+
+	"github.com/kelvyne/as3/bytecode"
+)
+
+func TestField_InRange(t *testing.T) {
+	level := Field{Min: 1, MinOp: "<", Max: 200, MaxOp: ">"}
+	exclusive := Field{Min: 0, MinOp: "<=", Max: 10, MaxOp: ">="}
+	tests := []struct {
+		name string
+		f    Field
+		v    float64
+		want bool
+	}{
+		{"no constraint", Field{}, -42, true},
+		{"min", level, 1, true},
+		{"below min", level, 0, false},
+		{"max", level, 200, true},
+		{"above max", level, 201, false},
+		{"exclusive min", exclusive, 0, false},
+		{"exclusive max", exclusive, 10, false},
+		{"inside", exclusive, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.f.InRange(tt.v); got != tt.want {
+				t.Errorf("Field.InRange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConstraint_apply(t *testing.T) {
+	var f Field
+	constraint{op: "<", value: -1}.apply(&f)
+	constraint{op: ">=", value: 8}.apply(&f)
+	if f.Min != -1 || f.MinOp != "<" || f.Max != 8 || f.MaxOp != ">=" {
+		t.Errorf("unexpected constraints %v %v %v %v", f.MinOp, f.Min, f.MaxOp, f.Max)
+	}
+}
+
+func Test_builder_constraintAt(t *testing.T) {
+	b := &builder{}
+	is := []bytecode.Instr{
+		{Model: bytecode.InstrModel{Name: "getlocal"}, Operands: []uint32{3}},
+		{Model: bytecode.InstrModel{Name: "pushbyte"}, Operands: []uint32{0xff}},
+		{Model: bytecode.InstrModel{Name: "ifnlt"}, Operands: []uint32{12}},
+		{Model: bytecode.InstrModel{Name: "getlocal"}, Operands: []uint32{4}},
+		{Model: bytecode.InstrModel{Name: "iflt"}, Operands: []uint32{12}},
+	}
+	c, ok := b.constraintAt(nil, is, 2)
+	if !ok || c.local != 3 || c.op != "<" || c.value != -1 {
+		t.Errorf("constraintAt(2) = %+v, %v", c, ok)
+	}
+	if _, ok = b.constraintAt(nil, is, 4); ok {
+		t.Errorf("expected a comparison between locals to be ignored")
+	}
+}
@@ -0,0 +1,235 @@
+package d2protocolparser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kelvyne/as3"
+	"github.com/kelvyne/as3/bytecode"
+)
+
+// ErrExtractReadMismatch means that a field is read with a method that does
+// not match its write method
+var ErrExtractReadMismatch = errors.New("read method does not match write method")
+
+// ErrExtractReadLengthMismatch means that the length of a vector field is read
+// with a method that does not match its write method
+var ErrExtractReadLengthMismatch = errors.New("vector length read method does not match write method")
+
+// ErrExtractReadTypeManagerMismatch means that only one of the serialize and
+// deserialize methods uses the ProtocolTypeManager for a field
+var ErrExtractReadTypeManagerMismatch = errors.New("TypeManager usage differs between serialize and deserialize")
+
+// readMethodsMap lists the read methods matching a write method
+var readMethodsMap = map[string][]string{
+	"writeByte":        {"readByte", "readUnsignedByte"},
+	"writeShort":       {"readShort", "readUnsignedShort"},
+	"writeInt":         {"readInt", "readUnsignedInt"},
+	"writeUnsignedInt": {"readUnsignedInt", "readInt"},
+	"writeVarShort":    {"readVarShort", "readVarUhShort"},
+	"writeVarInt":      {"readVarInt", "readVarUhInt"},
+	"writeVarLong":     {"readVarLong", "readVarUhLong"},
+	"writeFloat":       {"readFloat"},
+	"writeDouble":      {"readDouble"},
+	"writeUTF":         {"readUTF"},
+	"writeBoolean":     {"readBoolean"},
+	"writeBytes":       {"readBytes"},
+}
+
+// readMatchesWrite reports whether a value written with write can be read
+// with read. Unknown write methods always match.
+func readMatchesWrite(write, read string) bool {
+	reads, ok := readMethodsMap[write]
+	if !ok {
+		return true
+	}
+	for _, r := range reads {
+		if r == read {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldRead is what the deserialize methods tell about a field
+type fieldRead struct {
+	Method         string
+	LengthMethod   string
+	UseTypeManager bool
+	Constraints    []constraint
+}
+
+// localIndex returns the register used by a getlocal or setlocal instruction
+func localIndex(instr bytecode.Instr, prefix string) (uint32, bool) {
+	name := instr.Model.Name
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+	suffix := strings.TrimPrefix(strings.TrimPrefix(name, prefix), "_")
+	if suffix == "" {
+		if len(instr.Operands) == 0 {
+			return 0, false
+		}
+		return instr.Operands[0], true
+	}
+	n, err := strconv.Atoi(suffix)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(n), true
+}
+
+// deserializeMethods returns the deserializeAs_ method of a class and the
+// _xxxFunc methods it uses to read single fields. The _xxxtreeFunc methods
+// used by the asynchronous deserialization are ignored.
+func (b *builder) deserializeMethods(abc *as3.AbcFile, class as3.Class) []as3.Method {
+	var methods []as3.Method
+	for _, t := range class.InstanceTraits.Methods {
+		isDeserialize := strings.HasPrefix(t.Name, "deserializeAs_")
+		isFunc := strings.HasPrefix(t.Name, "_") && strings.HasSuffix(t.Name, "Func") && !strings.HasSuffix(t.Name, "treeFunc")
+		if isDeserialize || isFunc {
+			methods = append(methods, abc.Methods[t.Source.Method])
+		}
+	}
+	return methods
+}
+
+// extractDeserializeMethods analyzes the read side of a class and returns
+// what it tells about each field, indexed by field name
+func (b *builder) extractDeserializeMethods(abc *as3.AbcFile, class as3.Class, fields map[string]*Field) (map[string]*fieldRead, error) {
+	reads := map[string]*fieldRead{}
+	get := func(name string) *fieldRead {
+		r, ok := reads[name]
+		if !ok {
+			r = &fieldRead{}
+			reads[name] = r
+		}
+		return r
+	}
+
+	for _, m := range b.deserializeMethods(abc, class) {
+		if err := m.BodyInfo.Disassemble(); err != nil {
+			return nil, newExtractError(class.Name, class.Namespace, "", err)
+		}
+		instrs := m.BodyInfo.Instructions
+
+		// stackRead and stackTypeManager describe the value on top of the stack
+		var stackRead string
+		var stackTypeManager bool
+		localReads := map[uint32]string{}
+		localTypeManager := map[uint32]bool{}
+		// localConstraints holds the checks done on vector elements before
+		// they are pushed
+		localConstraints := map[uint32][]constraint{}
+		// pushed is the last vector field pushed to, its length is read in the
+		// loop condition that follows the loop body
+		var pushed string
+
+		for i, instr := range instrs {
+			name := instr.Model.Name
+			if c, ok := b.constraintAt(abc, instrs, i); ok {
+				if c.field != "" {
+					if _, ok := fields[c.field]; ok {
+						get(c.field).Constraints = append(get(c.field).Constraints, c)
+					}
+				} else {
+					localConstraints[c.local] = append(localConstraints[c.local], c)
+				}
+				stackRead, stackTypeManager = "", false
+				continue
+			}
+			switch {
+			case name == "callproperty":
+				multiname := abc.Source.ConstantPool.Multinames[instr.Operands[0]]
+				method := abc.Source.ConstantPool.Strings[multiname.Name]
+				stackRead = ""
+				stackTypeManager = false
+				if method == "getInstance" {
+					stackTypeManager = true
+				} else if strings.HasPrefix(method, "read") {
+					stackRead = method
+				}
+			case strings.HasPrefix(name, "convert") || strings.HasPrefix(name, "coerce") || strings.HasPrefix(name, "astype"):
+				// conversions keep the value on the stack
+			case strings.HasPrefix(name, "setlocal"):
+				if reg, ok := localIndex(instr, "setlocal"); ok {
+					localReads[reg] = stackRead
+					localTypeManager[reg] = stackTypeManager
+					delete(localConstraints, reg)
+				}
+				stackRead, stackTypeManager = "", false
+			case name == "setproperty" || name == "initproperty":
+				multiname := abc.Source.ConstantPool.Multinames[instr.Operands[0]]
+				prop := abc.Source.ConstantPool.Strings[multiname.Name]
+				if _, ok := fields[prop]; ok && isPublicQName(abc, multiname) && (stackRead != "" || stackTypeManager) {
+					r := get(prop)
+					r.Method = stackRead
+					r.UseTypeManager = stackTypeManager
+				}
+				stackRead, stackTypeManager = "", false
+			case name == "callpropvoid" && i >= 2:
+				stackRead, stackTypeManager = "", false
+				multiname := abc.Source.ConstantPool.Multinames[instr.Operands[0]]
+				if abc.Source.ConstantPool.Strings[multiname.Name] != "push" {
+					break
+				}
+				getProp := instrs[i-2]
+				reg, ok := localIndex(instrs[i-1], "getlocal")
+				if getProp.Model.Name != "getproperty" || !ok {
+					break
+				}
+				propMultiname := abc.Source.ConstantPool.Multinames[getProp.Operands[0]]
+				prop := abc.Source.ConstantPool.Strings[propMultiname.Name]
+				if f, ok := fields[prop]; !ok || !f.IsVector {
+					break
+				}
+				r := get(prop)
+				if localReads[reg] != "" {
+					r.Method = localReads[reg]
+				}
+				r.UseTypeManager = r.UseTypeManager || localTypeManager[reg]
+				r.Constraints = append(r.Constraints, localConstraints[reg]...)
+				pushed = prop
+			case strings.HasPrefix(name, "if") && i >= 2:
+				// for (_i < _len) compiles to getlocal _i, getlocal _len, iflt
+				reg, ok := localIndex(instrs[i-1], "getlocal")
+				_, isLocal := localIndex(instrs[i-2], "getlocal")
+				if ok && isLocal && pushed != "" && localReads[reg] != "" {
+					get(pushed).LengthMethod = localReads[reg]
+				}
+				pushed = ""
+			default:
+				stackRead, stackTypeManager = "", false
+			}
+		}
+	}
+	return reads, nil
+}
+
+// crossCheck compares the serialize side of the fields with what the
+// deserialize methods read
+func crossCheck(class as3.Class, fields []Field, reads map[string]*fieldRead) []error {
+	var errs []error
+	for _, f := range fields {
+		r, ok := reads[f.Name]
+		if !ok {
+			continue
+		}
+		mismatch := func(err error, write, read string) {
+			err = fmt.Errorf("%w: %v and %v", err, write, read)
+			errs = append(errs, newExtractError(class.Name, class.Namespace, f.Name, err))
+		}
+		if f.WriteMethod != "" && r.Method != "" && !readMatchesWrite(f.WriteMethod, r.Method) {
+			mismatch(ErrExtractReadMismatch, f.WriteMethod, r.Method)
+		}
+		if f.WriteLengthMethod != "" && r.LengthMethod != "" && !readMatchesWrite(f.WriteLengthMethod, r.LengthMethod) {
+			mismatch(ErrExtractReadLengthMismatch, f.WriteLengthMethod, r.LengthMethod)
+		}
+		if f.UseTypeManager != r.UseTypeManager && (r.UseTypeManager || r.Method != "") {
+			errs = append(errs, newExtractError(class.Name, class.Namespace, f.Name, ErrExtractReadTypeManagerMismatch))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,90 @@
+package d2protocolparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kelvyne/as3"
+	"github.com/kelvyne/as3/bytecode"
+)
+
+func TestReadMatchesWrite(t *testing.T) {
+	tests := []struct {
+		write string
+		read  string
+		want  bool
+	}{
+		{"writeVarShort", "readVarUhShort", true},
+		{"writeVarShort", "readShort", false},
+		{"writeInt", "readUnsignedInt", true},
+		{"writeByte", "readUnsignedByte", true},
+		{"writeUTF", "readBoolean", false},
+		{"writeSomething", "readAnything", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.write+"/"+tt.read, func(t *testing.T) {
+			if got := readMatchesWrite(tt.write, tt.read); got != tt.want {
+				t.Errorf("readMatchesWrite() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		instr  bytecode.Instr
+		prefix string
+		want   uint32
+		wantOk bool
+	}{
+		{"operand", bytecode.Instr{Model: bytecode.InstrModel{Name: "getlocal"}, Operands: []uint32{5}}, "getlocal", 5, true},
+		{"implicit", bytecode.Instr{Model: bytecode.InstrModel{Name: "setlocal2"}}, "setlocal", 2, true},
+		{"underscore", bytecode.Instr{Model: bytecode.InstrModel{Name: "getlocal_3"}}, "getlocal", 3, true},
+		{"other", bytecode.Instr{Model: bytecode.InstrModel{Name: "getproperty"}, Operands: []uint32{1}}, "getlocal", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := localIndex(tt.instr, tt.prefix)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("localIndex() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestCrossCheck(t *testing.T) {
+	class := as3.Class{Name: "Foo", Namespace: "com.x"}
+	fields := []Field{
+		{Name: "ok", WriteMethod: "writeVarShort"},
+		{Name: "bad", WriteMethod: "writeVarShort"},
+		{Name: "badLength", IsVector: true, WriteMethod: "writeUTF", WriteLengthMethod: "writeShort"},
+		{Name: "typeManager", UseTypeManager: true},
+		{Name: "unread", WriteMethod: "writeByte"},
+	}
+	reads := map[string]*fieldRead{
+		"ok":          {Method: "readVarUhShort"},
+		"bad":         {Method: "readShort"},
+		"badLength":   {Method: "readUTF", LengthMethod: "readVarInt"},
+		"typeManager": {Method: "readInt"},
+	}
+
+	errs := crossCheck(class, fields, reads)
+	want := []struct {
+		field string
+		err   error
+	}{
+		{"bad", ErrExtractReadMismatch},
+		{"badLength", ErrExtractReadLengthMismatch},
+		{"typeManager", ErrExtractReadTypeManagerMismatch},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("crossCheck() = %v, want %v errors", errs, len(want))
+	}
+	for i, w := range want {
+		var e *ExtractError
+		if !errors.As(errs[i], &e) || e.Field != w.field || !errors.Is(errs[i], w.err) {
+			t.Errorf("crossCheck()[%v] = %v, want %v on %v", i, errs[i], w.err, w.field)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package d2protocolparser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrFatalDiagnostics means that BuildWithDiagnostics found at least one
+// fatal diagnostic
+var ErrFatalDiagnostics = errors.New("fatal diagnostics found")
+
+// Severity tells how bad a Diagnostic is
+type Severity int
+
+const (
+	// SeverityWarning is used for problems that leave a Field incomplete or
+	// suspicious, such as a read method not matching the write method
+	SeverityWarning Severity = iota
+	// SeverityError is used for problems that leave a Class or an Enum out
+	// of the Protocol
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Diagnostic is a single problem found while building a Protocol
+type Diagnostic struct {
+	Severity  Severity
+	Class     string
+	Namespace string
+	Field     string // Field is empty when the problem is not tied to a field
+	// Instruction is the index of the offending instruction in the
+	// disassembled method, or -1 when unknown
+	Instruction int
+	Err         error
+}
+
+func (d Diagnostic) String() string {
+	e := ExtractError{d.Class, d.Namespace, d.Field, d.Instruction, d.Err}
+	return fmt.Sprintf("%v: %v", d.Severity, &e)
+}
+
+func newDiagnostic(s Severity, class, namespace string, err error) Diagnostic {
+	d := Diagnostic{s, class, namespace, "", -1, err}
+	var e *ExtractError
+	if errors.As(err, &e) {
+		d.Field = e.Field
+		d.Instruction = e.Instruction
+		d.Err = e.Err
+	}
+	return d
+}
+
+func fatalDiagnostics(diags []Diagnostic, fatalWarnings bool) int {
+	var n int
+	for _, d := range diags {
+		if d.Severity == SeverityError || fatalWarnings {
+			n++
+		}
+	}
+	return n
+}
+
+// BuildWithDiagnostics builds the Protocol from a DofusInvoker.swf read from
+// r without stopping on the first problem. Classes and enums that cannot be
+// extracted are left out of the returned Protocol and reported as errors.
+// Fields rejected by Verify or whose deserialize method disagrees with the
+// serialize method are kept and reported as warnings.
+//
+// The partial Protocol and the diagnostics are returned even when the error
+// is ErrFatalDiagnostics, which happens when an error is found, or a warning
+// with Options.FatalWarnings set. Any other error means the swf could not be
+// read.
+func BuildWithDiagnostics(r io.Reader, opts Options) (*Protocol, []Diagnostic, error) {
+	s, c, err := parseSwf(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := parseAbc(s, opts.ABCTags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b := builder{abcFiles: a, opts: opts, keepGoing: true}
+	p, err := b.Build()
+	if err != nil {
+		return nil, b.diagnostics, err
+	}
+	p.Container = c
+	if !opts.SkipVerify {
+		for _, e := range verifyAll(&p) {
+			b.diagnostics = append(b.diagnostics, Diagnostic{SeverityWarning, e.Class.Name, e.Class.Namespace, e.Field.Name, -1, e.Err})
+		}
+	}
+
+	if n := fatalDiagnostics(b.diagnostics, opts.FatalWarnings); n > 0 {
+		return &p, b.diagnostics, newError(ErrFatalDiagnostics, fmt.Sprintf("%v fatal diagnostics", n))
+	}
+	return &p, b.diagnostics, nil
+}
@@ -0,0 +1,78 @@
+package d2protocolparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kelvyne/as3"
+	"github.com/kelvyne/as3/bytecode"
+)
+
+func TestDiagnostic_String(t *testing.T) {
+	err := errors.New("boom")
+	tests := []struct {
+		name string
+		d    Diagnostic
+		want string
+	}{
+		{"class", Diagnostic{SeverityError, "Foo", "com.x", "", -1, err}, "error: com.x.Foo: boom"},
+		{"field", Diagnostic{SeverityWarning, "Foo", "com.x", "bar", -1, err}, "warning: com.x.Foo:bar: boom"},
+		{"instruction", Diagnostic{SeverityError, "Foo", "com.x", "", 12, err}, "error: com.x.Foo at instruction 12: boom"},
+		{"no namespace", Diagnostic{SeverityError, "BuildInfos", "", "", -1, err}, "error: BuildInfos: boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.d.String(); got != tt.want {
+				t.Errorf("Diagnostic.String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDiagnostic(t *testing.T) {
+	err := errors.New("boom")
+	e := newExtractError("Foo", "com.x", "bar", err)
+	e.Instruction = 7
+	d := newDiagnostic(SeverityError, "Foo", "com.x", newError(e, "wrapped"))
+	if d.Field != "bar" || d.Instruction != 7 || d.Err != err {
+		t.Errorf("expected field, instruction and cause of the ExtractError, got %+v", d)
+	}
+	d = newDiagnostic(SeverityError, "Foo", "com.x", err)
+	if d.Instruction != -1 || d.Err != err {
+		t.Errorf("expected instruction -1, got %v %v", d.Instruction, d.Err)
+	}
+}
+
+func TestFatalDiagnostics(t *testing.T) {
+	diags := []Diagnostic{
+		{Severity: SeverityWarning},
+		{Severity: SeverityError},
+		{Severity: SeverityWarning},
+	}
+	if got := fatalDiagnostics(diags, false); got != 1 {
+		t.Errorf("fatalDiagnostics() = %v, want 1", got)
+	}
+	if got := fatalDiagnostics(diags, true); got != 3 {
+		t.Errorf("fatalDiagnostics() with warnings = %v, want 3", got)
+	}
+}
+
+func Test_builder_Build_NoBuildInfos(t *testing.T) {
+	abc := &as3.AbcFile{Source: &bytecode.AbcFile{}}
+	b := builder{abcFiles: []*as3.AbcFile{abc}, keepGoing: true}
+	if _, err := b.Build(); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if len(b.diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", b.diagnostics)
+	}
+	d := b.diagnostics[0]
+	if d.Severity != SeverityError || d.Class != buildInfosName || !errors.Is(d.Err, ErrExtractNoBuildInfos) {
+		t.Errorf("unexpected diagnostic %v", d)
+	}
+
+	b = builder{abcFiles: []*as3.AbcFile{abc}}
+	if _, err := b.Build(); !errors.Is(err, ErrExtractNoBuildInfos) {
+		t.Errorf("expected ErrExtractNoBuildInfos, got %v", err)
+	}
+}
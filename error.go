@@ -1,16 +1,63 @@
 package d2protocolparser
 
-import "fmt"
+import (
+	"bytes"
+	"fmt"
+)
 
-type protocolError struct {
-	err error
-	msg string
+// ProtocolError is returned by the Build functions. Err is the underlying
+// cause and may be nil.
+type ProtocolError struct {
+	Err error
+	Msg string
 }
 
 func newError(err error, msg string) error {
-	return &protocolError{err, msg}
+	return &ProtocolError{err, msg}
 }
 
-func (e *protocolError) Error() string {
-	return fmt.Sprintf("d2protocolparser error: %v (%v)", e.msg, e.err)
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("d2protocolparser error: %v (%v)", e.Msg, e.Err)
+}
+
+// Unwrap returns the underlying cause
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+// ExtractError is returned when a class, an enum or the version cannot be
+// extracted from the abc file
+type ExtractError struct {
+	Class     string
+	Namespace string
+	Field     string // Field is empty when the error is not tied to a field
+	// Instruction is the index of the offending instruction in the
+	// disassembled method, or -1 when unknown
+	Instruction int
+	Err         error
+}
+
+func newExtractError(class, namespace, field string, err error) *ExtractError {
+	return &ExtractError{class, namespace, field, -1, err}
+}
+
+func (e *ExtractError) Error() string {
+	var buf bytes.Buffer
+	if e.Namespace != "" {
+		fmt.Fprintf(&buf, "%v.", e.Namespace)
+	}
+	buf.WriteString(e.Class)
+	if e.Field != "" {
+		fmt.Fprintf(&buf, ":%v", e.Field)
+	}
+	if e.Instruction >= 0 {
+		fmt.Fprintf(&buf, " at instruction %v", e.Instruction)
+	}
+	fmt.Fprintf(&buf, ": %v", e.Err)
+	return buf.String()
+}
+
+// Unwrap returns the underlying cause
+func (e *ExtractError) Unwrap() error {
+	return e.Err
 }
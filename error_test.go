@@ -0,0 +1,47 @@
+package d2protocolparser
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExtractError_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *ExtractError
+		want string
+	}{
+		{"class", &ExtractError{"Foo", "com.x", "", -1, ErrExtractNoProtocolID}, "com.x.Foo: no protocolId found"},
+		{"field", &ExtractError{"Foo", "com.x", "bar", 3, ErrExtractUnknownField}, "com.x.Foo:bar at instruction 3: field not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("ExtractError.Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrors_Unwrap(t *testing.T) {
+	extractErr := newError(newExtractError("Foo", "com.x", "", ErrExtractNoProtocolID), "protocol build failed")
+	if !errors.Is(extractErr, ErrExtractNoProtocolID) {
+		t.Errorf("expected errors.Is to find ErrExtractNoProtocolID in %v", extractErr)
+	}
+	var e *ExtractError
+	if !errors.As(extractErr, &e) || e.Class != "Foo" {
+		t.Errorf("expected errors.As to find the ExtractError in %v", extractErr)
+	}
+
+	p := &Protocol{Types: []Class{
+		{Name: "Foo", Fields: []Field{{Name: "bar", Type: "int"}}},
+	}}
+	verifyErr := newError(Verify(p), "verification error")
+	if !errors.Is(verifyErr, ErrVerifyScalarNoWrite) {
+		t.Errorf("expected errors.Is to find ErrVerifyScalarNoWrite in %v", verifyErr)
+	}
+	var v *VerifyError
+	if !errors.As(verifyErr, &v) || v.Class.Name != "Foo" || v.Field.Name != "bar" {
+		t.Errorf("expected errors.As to find the VerifyError in %v", verifyErr)
+	}
+}
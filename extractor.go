@@ -1,7 +1,7 @@
 package d2protocolparser
 
 import (
-	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -24,95 +24,126 @@ var ErrExtractProtocolIDNotInt = errors.New("protocolId not an int trait")
 // ErrExtractNoBuildInfos means that the class BuildInfos was not found
 var ErrExtractNoBuildInfos = errors.New("no BuildInfos found")
 
-func (b *builder) ExtractEnum(class as3.Class) (Enum, error) {
+// ErrExtractEnumValue means that an enumeration value is neither a literal
+// nor set from a literal in the static initializer
+var ErrExtractEnumValue = errors.New("enumeration value is not a literal")
+
+// ErrExtractNoSerializeMethod means that the serializeAs_ method of a class
+// could not be found
+var ErrExtractNoSerializeMethod = errors.New("serialize method not found")
+
+// ErrExtractUnknownField means that the serialize method writes a property
+// that is not a field of the class
+var ErrExtractUnknownField = errors.New("field not found")
+
+// ErrExtractNotVector means that a vector write is used on a non-vector field
+var ErrExtractNotVector = errors.New("field is not a vector")
+
+// ErrExtractTypeIDWrite means that the type id of a TypeManager field is not
+// written with writeShort
+var ErrExtractTypeIDWrite = errors.New("invalid write method for getTypeId")
+
+// ErrExtractVectorWrite means that the elements of a vector of scalar types
+// are not written with a write method
+var ErrExtractVectorWrite = errors.New("invalid write method for vector of scalar types")
+
+// ErrExtractNoDynamicVector means that a static vector length was found
+// without a matching vector field
+var ErrExtractNoDynamicVector = errors.New("vector length found but no dynamic vector")
+
+// ErrExtractBBWNotBoolean means that BooleanByteWrapper is used on a non
+// boolean field
+var ErrExtractBBWNotBoolean = errors.New("usage of BooleanByteWrapper on non boolean field")
+
+// ErrExtractVersionInstr means that an unexpected instruction was found when
+// extracting the version
+var ErrExtractVersionInstr = errors.New("unexpected instruction when extracting version")
+
+func (b *builder) ExtractEnum(abc *as3.AbcFile, class as3.Class) (Enum, error) {
 	var values []EnumValue
+	var initialized map[string]Value
 	for _, trait := range class.ClassTraits.Slots {
-		if trait.Source.VKind != bytecode.SlotKindInt {
-			return Enum{}, fmt.Errorf("enumeration value %v of %v is not an uint", trait.Name, class.Name)
+		value, err := b.staticValue(abc, class, trait, &initialized, ErrExtractEnumValue)
+		if err != nil {
+			return Enum{}, err
 		}
-		name := trait.Name
-		value := b.abcFile.Source.ConstantPool.Integers[trait.Source.VIndex]
-		values = append(values, EnumValue{name, value})
+		values = append(values, EnumValue{trait.Name, value})
 	}
 	return Enum{class.Name, values}, nil
 }
 
-func (b *builder) ExtractClass(class as3.Class) (Class, error) {
+func (b *builder) ExtractClass(abc *as3.AbcFile, class as3.Class) (Class, error) {
 	trait, found := findMethodWithPrefix(class, "serializeAs_")
 	if !found {
-		return Class{}, fmt.Errorf("serialize method not found in class %v", class.Name)
+		return Class{}, newExtractError(class.Name, class.Namespace, "", ErrExtractNoSerializeMethod)
 	}
 
-	m := b.abcFile.Methods[trait.Method]
+	m := abc.Methods[trait.Method]
 	if err := m.BodyInfo.Disassemble(); err != nil {
-		return Class{}, fmt.Errorf("failed to disassemble %v", class.Name)
+		return Class{}, newExtractError(class.Name, class.Namespace, "", err)
 	}
 
-	fields, err := b.extractMessageFields(class)
+	fields, err := b.extractMessageFields(abc, class)
 	if err != nil {
-		return Class{}, fmt.Errorf("failed retrieve %v's fields", class.Name)
+		return Class{}, newExtractError(class.Name, class.Namespace, "", err)
 	}
 
 	fieldMap := map[string]*Field{}
 	for i, f := range fields {
+		fields[i].Order = -1
 		fieldMap[f.Name] = &fields[i]
 	}
 
-	if err = b.extractSerializeMethods(class, m, fieldMap); err != nil {
+	consumed, boxes, err := b.extractSerializeMethods(abc, class, m.BodyInfo.Instructions, fieldMap)
+	if err != nil {
+		return Class{}, err
+	}
+	if b.auditing {
+		if a := b.auditSerialize(abc, class, m.BodyInfo.Instructions, consumed); len(a.Sequences) > 0 {
+			b.audits = append(b.audits, a)
+		}
+	}
+
+	reads, err := b.extractDeserializeMethods(abc, class, fieldMap)
+	if err != nil {
 		return Class{}, err
 	}
+	for _, err := range crossCheck(class, fields, reads) {
+		b.diagnostics = append(b.diagnostics, newDiagnostic(SeverityWarning, class.Name, class.Namespace, err))
+	}
+	for i, f := range fields {
+		if r, ok := reads[f.Name]; ok {
+			for _, c := range r.Constraints {
+				c.apply(&fields[i])
+			}
+		}
+	}
 
 	for i := range fields {
 		reduceType(&fields[i])
 		reduceMethod(&fields[i])
 	}
+	sortFields(fields)
+	indexBoxes(boxes, fields)
 
-	protocolID, err := b.extractProtocolID(class)
+	protocolID, err := b.extractProtocolID(abc, class)
 	if err != nil {
-		return Class{}, err
+		return Class{}, newExtractError(class.Name, class.Namespace, "", err)
 	}
 
-	useHashFunc, err := b.extractUseHashFunc(class)
+	hash, err := b.extractHash(abc, class)
+	if err != nil {
+		return Class{}, newExtractError(class.Name, class.Namespace, "", err)
+	}
 
 	superName := class.SuperName
 	if superName == "Object" || superName == "NetworkMessage" {
 		superName = ""
 	}
-	return Class{class.Name, class.Namespace, superName, fields, protocolID, useHashFunc}, nil
-}
-
-func (b *builder) extractUseHashFunc(class as3.Class) (bool, error) {
-	getPackFunc := func(c as3.Class) (bool, as3.Method) {
-		for _, m := range c.InstanceTraits.Methods {
-			if m.Name == "pack" {
-				return true, b.abcFile.Methods[m.Source.Method]
-			}
-		}
-		return false, as3.Method{}
-	}
-	f, m := getPackFunc(class)
-	if !f {
-		return false, nil
-	}
-	if err := m.BodyInfo.Disassemble(); err != nil {
-		return false, fmt.Errorf("could not disassemble pack method: %v", err)
-	}
-
-	for _, instr := range m.BodyInfo.Instructions {
-		if instr.Model.Name == "getlex" {
-			multiname := b.abcFile.Source.ConstantPool.Multinames[instr.Operands[0]]
-			if multiname.Kind == bytecode.MultinameKindQName {
-				name := b.abcFile.Source.ConstantPool.Strings[multiname.Name]
-				if name == "HASH_FUNCTION" {
-					return true, nil
-				}
-			}
-		}
-	}
-	return false, nil
+	return Class{class.Name, class.Namespace, superName, fields, protocolID, hash.Function != "", hash, DirectionUnknown, boxes}, nil
 }
 
-func (b *builder) extractProtocolID(class as3.Class) (uint16, error) {
+func (b *builder) extractProtocolID(abc *as3.AbcFile, class as3.Class) (uint16, error) {
 	for _, t := range class.ClassTraits.Slots {
 		if t.Name == "protocolId" {
 			if t.Source.Kind != bytecode.TraitsInfoConst {
@@ -121,20 +152,20 @@ func (b *builder) extractProtocolID(class as3.Class) (uint16, error) {
 			if t.Source.VKind != bytecode.SlotKindInt {
 				return 0, ErrExtractProtocolIDNotInt
 			}
-			id := b.abcFile.Source.ConstantPool.Integers[t.Source.VIndex]
+			id := abc.Source.ConstantPool.Integers[t.Source.VIndex]
 			return uint16(id), nil
 		}
 	}
 	return 0, ErrExtractNoProtocolID
 }
 
-func (b *builder) extractMessageFields(class as3.Class) (f []Field, err error) {
+func (b *builder) extractMessageFields(abc *as3.AbcFile, class as3.Class) (f []Field, err error) {
 	createField := func(name string, typeId uint32) Field {
-		t := b.abcFile.Source.ConstantPool.MultinameString(typeId)
+		t := abc.Source.ConstantPool.MultinameString(typeId)
 		var isVector bool
 		if strings.HasPrefix(t, "Vector<") {
-			typename := b.abcFile.Source.ConstantPool.Multinames[typeId]
-			param := b.abcFile.Source.ConstantPool.MultinameString(typename.Params[0])
+			typename := abc.Source.ConstantPool.Multinames[typeId]
+			param := abc.Source.ConstantPool.MultinameString(typename.Params[0])
 			t = param
 			isVector = true
 		} else if t == "ByteArray" {
@@ -145,8 +176,8 @@ func (b *builder) extractMessageFields(class as3.Class) (f []Field, err error) {
 	}
 
 	for _, slot := range class.InstanceTraits.Slots {
-		name := b.abcFile.Source.ConstantPool.Multinames[slot.Source.Name]
-		if !isPublicNamespace(b.abcFile, name.Namespace) {
+		name := abc.Source.ConstantPool.Multinames[slot.Source.Name]
+		if !isPublicNamespace(abc, name.Namespace) {
 			continue
 		}
 		field := createField(slot.Name, slot.Source.Typename)
@@ -162,28 +193,31 @@ func (b *builder) extractMessageFields(class as3.Class) (f []Field, err error) {
 		setter     bool
 	}
 	getSetters := map[string]*getSetter{}
+	var getSetterNames []string
 
 	for _, m := range class.InstanceTraits.Methods {
 		isGetter := m.Source.Kind == bytecode.TraitsInfoGetter
 		isSetter := m.Source.Kind == bytecode.TraitsInfoSetter
-		name := b.abcFile.Source.ConstantPool.Multinames[m.Source.Name]
-		if !(isGetter || isSetter) || !isPublicNamespace(b.abcFile, name.Namespace) {
+		name := abc.Source.ConstantPool.Multinames[m.Source.Name]
+		if !(isGetter || isSetter) || !isPublicNamespace(abc, name.Namespace) {
 			continue
 		}
 		v, ok := getSetters[m.Name]
 		if !ok {
 			v = &getSetter{}
 			getSetters[m.Name] = v
+			getSetterNames = append(getSetterNames, m.Name)
 		}
 		v.getter = v.getter || isGetter
 		v.setter = v.setter || isSetter
 		if isGetter {
-			info := b.abcFile.Source.Methods[m.Source.Method]
+			info := abc.Source.Methods[m.Source.Method]
 			v.getterType = info.ReturnType
 		}
 	}
 
-	for name, gs := range getSetters {
+	for _, name := range getSetterNames {
+		gs := getSetters[name]
 		if !(gs.getter && gs.setter) {
 			continue
 		}
@@ -193,17 +227,17 @@ func (b *builder) extractMessageFields(class as3.Class) (f []Field, err error) {
 	return
 }
 
-func handleSimpleProp(b *builder, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
+func handleSimpleProp(abc *as3.AbcFile, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
 	get := instrs[0]
 	call := instrs[1]
-	getMultiname := b.abcFile.Source.ConstantPool.Multinames[get.Operands[0]]
-	callMultiname := b.abcFile.Source.ConstantPool.Multinames[call.Operands[0]]
-	if !isPublicQName(b.abcFile, getMultiname) {
+	getMultiname := abc.Source.ConstantPool.Multinames[get.Operands[0]]
+	callMultiname := abc.Source.ConstantPool.Multinames[call.Operands[0]]
+	if !isPublicQName(abc, getMultiname) {
 		return nil, nil
 	}
 
-	prop := b.abcFile.Source.ConstantPool.Strings[getMultiname.Name]
-	writeMethod := b.abcFile.Source.ConstantPool.Strings[callMultiname.Name]
+	prop := abc.Source.ConstantPool.Strings[getMultiname.Name]
+	writeMethod := abc.Source.ConstantPool.Strings[callMultiname.Name]
 
 	if !strings.HasPrefix(writeMethod, "write") {
 		return nil, nil
@@ -211,35 +245,35 @@ func handleSimpleProp(b *builder, class as3.Class, fields map[string]*Field, ins
 
 	field, ok := fields[prop]
 	if !ok {
-		return nil, fmt.Errorf("%v.%v.%v field not found", class.Namespace, class.Name, prop)
+		return nil, newExtractError(class.Name, class.Namespace, prop, ErrExtractUnknownField)
 	}
 
 	field.WriteMethod = writeMethod
 	return field, nil
 }
 
-func handleVecPropLength(b *builder, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
+func handleVecPropLength(abc *as3.AbcFile, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
 	get := instrs[0]
 	getLen := instrs[1]
 	call := instrs[2]
 
-	getMultiname := b.abcFile.Source.ConstantPool.Multinames[get.Operands[0]]
-	getLenMultiname := b.abcFile.Source.ConstantPool.Multinames[getLen.Operands[0]]
-	callMultiname := b.abcFile.Source.ConstantPool.Multinames[call.Operands[0]]
-	if !isPublicQName(b.abcFile, getMultiname) || !isPublicQName(b.abcFile, getLenMultiname) {
+	getMultiname := abc.Source.ConstantPool.Multinames[get.Operands[0]]
+	getLenMultiname := abc.Source.ConstantPool.Multinames[getLen.Operands[0]]
+	callMultiname := abc.Source.ConstantPool.Multinames[call.Operands[0]]
+	if !isPublicQName(abc, getMultiname) || !isPublicQName(abc, getLenMultiname) {
 		return nil, nil
 	}
 
-	if b.abcFile.Source.ConstantPool.Strings[getLenMultiname.Name] != "length" {
+	if abc.Source.ConstantPool.Strings[getLenMultiname.Name] != "length" {
 		return nil, nil
 	}
-	prop := b.abcFile.Source.ConstantPool.Strings[getMultiname.Name]
+	prop := abc.Source.ConstantPool.Strings[getMultiname.Name]
 
 	field, ok := fields[prop]
 	if !ok || !field.IsVector {
-		return nil, fmt.Errorf("%v.%v: write length on non-vector %v", class.Namespace, class.Name, prop)
+		return nil, newExtractError(class.Name, class.Namespace, prop, ErrExtractNotVector)
 	}
-	writeMethod := b.abcFile.Source.ConstantPool.Strings[callMultiname.Name]
+	writeMethod := abc.Source.ConstantPool.Strings[callMultiname.Name]
 
 	if !strings.HasPrefix(writeMethod, "write") {
 		return nil, nil
@@ -250,117 +284,117 @@ func handleVecPropLength(b *builder, class as3.Class, fields map[string]*Field,
 	return field, nil
 }
 
-func handleTypeManagerProp(b *builder, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
+func handleTypeManagerProp(abc *as3.AbcFile, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
 	get := instrs[0]
 	getType := instrs[1]
 	call := instrs[2]
 
-	getMultiname := b.abcFile.Source.ConstantPool.Multinames[get.Operands[0]]
-	getTypeMultiname := b.abcFile.Source.ConstantPool.Multinames[getType.Operands[0]]
-	callMultiname := b.abcFile.Source.ConstantPool.Multinames[call.Operands[0]]
+	getMultiname := abc.Source.ConstantPool.Multinames[get.Operands[0]]
+	getTypeMultiname := abc.Source.ConstantPool.Multinames[getType.Operands[0]]
+	callMultiname := abc.Source.ConstantPool.Multinames[call.Operands[0]]
 
-	if !isPublicQName(b.abcFile, getMultiname) || !isPublicQName(b.abcFile, getTypeMultiname) {
+	if !isPublicQName(abc, getMultiname) || !isPublicQName(abc, getTypeMultiname) {
 		return nil, nil
 	}
 
-	if b.abcFile.Source.ConstantPool.Strings[getTypeMultiname.Name] != "getTypeId" {
+	if abc.Source.ConstantPool.Strings[getTypeMultiname.Name] != "getTypeId" {
 		return nil, nil
 	}
 
-	prop := b.abcFile.Source.ConstantPool.Strings[getMultiname.Name]
+	prop := abc.Source.ConstantPool.Strings[getMultiname.Name]
 	field, ok := fields[prop]
 	if !ok {
-		return nil, fmt.Errorf("%v.%v: getTypeId on %v field", class.Namespace, class.Name, prop)
+		return nil, newExtractError(class.Name, class.Namespace, prop, ErrExtractUnknownField)
 	}
 
-	writeMethod := b.abcFile.Source.ConstantPool.Strings[callMultiname.Name]
+	writeMethod := abc.Source.ConstantPool.Strings[callMultiname.Name]
 	if writeMethod != "writeShort" {
-		return nil, fmt.Errorf("%v.%v: invalid %v for getTypeId", class.Namespace, class.Name, writeMethod)
+		return nil, newExtractError(class.Name, class.Namespace, prop, ErrExtractTypeIDWrite)
 	}
 
 	field.UseTypeManager = true
 	return field, nil
 }
 
-func handleVecScalarProp(b *builder, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
+func handleVecScalarProp(abc *as3.AbcFile, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
 	get := instrs[0]
 	getIndex := instrs[2]
-	getMultiname := b.abcFile.Source.ConstantPool.Multinames[get.Operands[0]]
-	getIndexMultiname := b.abcFile.Source.ConstantPool.Multinames[getIndex.Operands[0]]
-	if !isPublicQName(b.abcFile, getMultiname) || getIndexMultiname.Kind != bytecode.MultinameKindMultinameL {
+	getMultiname := abc.Source.ConstantPool.Multinames[get.Operands[0]]
+	getIndexMultiname := abc.Source.ConstantPool.Multinames[getIndex.Operands[0]]
+	if !isPublicQName(abc, getMultiname) || getIndexMultiname.Kind != bytecode.MultinameKindMultinameL {
 		return nil, nil
 	}
 
 	call := instrs[3]
-	callMultiname := b.abcFile.Source.ConstantPool.Multinames[call.Operands[0]]
+	callMultiname := abc.Source.ConstantPool.Multinames[call.Operands[0]]
 	if callMultiname.Kind != bytecode.MultinameKindQName {
 		return nil, nil
 	}
 
-	writeMethod := b.abcFile.Source.ConstantPool.Strings[callMultiname.Name]
+	prop := abc.Source.ConstantPool.Strings[getMultiname.Name]
+	writeMethod := abc.Source.ConstantPool.Strings[callMultiname.Name]
 	if !strings.HasPrefix(writeMethod, "write") {
-		return nil, fmt.Errorf("%v.%v: %v method for vector of scalar types", class.Namespace, class.Name, writeMethod)
+		return nil, newExtractError(class.Name, class.Namespace, prop, ErrExtractVectorWrite)
 	}
 
-	prop := b.abcFile.Source.ConstantPool.Strings[getMultiname.Name]
 	field, ok := fields[prop]
 	if !ok || !field.IsVector {
-		return nil, fmt.Errorf("%v.%v: vector of scalar write on %v field", class.Namespace, class.Name, prop)
+		return nil, newExtractError(class.Name, class.Namespace, prop, ErrExtractNotVector)
 	}
 	field.WriteMethod = writeMethod
 	return field, nil
 }
 
-func handleVecTypeManagerProp(b *builder, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
+func handleVecTypeManagerProp(abc *as3.AbcFile, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
 	get := instrs[0]
 	lex := instrs[3]
 	call := instrs[5]
-	getMultiname := b.abcFile.Source.ConstantPool.Multinames[get.Operands[0]]
-	lexMultiname := b.abcFile.Source.ConstantPool.Multinames[lex.Operands[0]]
-	callMultiname := b.abcFile.Source.ConstantPool.Multinames[call.Operands[0]]
+	getMultiname := abc.Source.ConstantPool.Multinames[get.Operands[0]]
+	lexMultiname := abc.Source.ConstantPool.Multinames[lex.Operands[0]]
+	callMultiname := abc.Source.ConstantPool.Multinames[call.Operands[0]]
 
-	if !isPublicQName(b.abcFile, getMultiname) {
+	if !isPublicQName(abc, getMultiname) {
 		return nil, nil
 	}
 
-	lexNs := b.abcFile.Source.ConstantPool.Namespaces[lexMultiname.Namespace]
-	lexNsName := b.abcFile.Source.ConstantPool.Strings[lexNs.Name]
+	lexNs := abc.Source.ConstantPool.Namespaces[lexMultiname.Namespace]
+	lexNsName := abc.Source.ConstantPool.Strings[lexNs.Name]
 	if !strings.HasPrefix(lexNsName, "com.ankamagames.dofus.network.types") {
 		return nil, nil
 	}
 
-	callName := b.abcFile.Source.ConstantPool.Strings[callMultiname.Name]
+	callName := abc.Source.ConstantPool.Strings[callMultiname.Name]
 	if callName != "getTypeId" {
 		return nil, nil
 	}
 
-	prop := b.abcFile.Source.ConstantPool.Strings[getMultiname.Name]
+	prop := abc.Source.ConstantPool.Strings[getMultiname.Name]
 	f, ok := fields[prop]
 	if !ok || !f.IsVector {
-		return nil, fmt.Errorf("%v.%v: %v field is not a vector", class.Namespace, class.Name, prop)
+		return nil, newExtractError(class.Name, class.Namespace, prop, ErrExtractNotVector)
 	}
 
 	f.UseTypeManager = true
 	return f, nil
 }
 
-func handleVecPropDynamicLen(b *builder, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
+func handleVecPropDynamicLen(abc *as3.AbcFile, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
 	push := instrs[5]
 	len := push.Operands[0]
 	if last == nil || !last.IsVector || last.IsDynamicLength {
-		return nil, errors.New("vector length found but no dynamic vector")
+		return nil, newExtractError(class.Name, class.Namespace, "", ErrExtractNoDynamicVector)
 	}
 	last.Length = len
 	return last, nil
 }
 
-func handleGetProperty(b *builder, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
+func handleGetProperty(abc *as3.AbcFile, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
 	get := instrs[0]
-	multi := b.abcFile.Source.ConstantPool.Multinames[get.Operands[0]]
-	if !isPublicQName(b.abcFile, multi) {
+	multi := abc.Source.ConstantPool.Multinames[get.Operands[0]]
+	if !isPublicQName(abc, multi) {
 		return nil, nil
 	}
-	name := b.abcFile.Source.ConstantPool.Strings[multi.Name]
+	name := abc.Source.ConstantPool.Strings[multi.Name]
 	field, ok := fields[name]
 	if !ok {
 		return nil, nil
@@ -368,10 +402,10 @@ func handleGetProperty(b *builder, class as3.Class, fields map[string]*Field, in
 	return field, nil
 }
 
-func handleBBWProp(b *builder, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
+func handleBBWProp(abc *as3.AbcFile, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
 	lex := instrs[0]
-	lexMultiname := b.abcFile.Source.ConstantPool.Multinames[lex.Operands[0]]
-	lexName := b.abcFile.Source.ConstantPool.Strings[lexMultiname.Name]
+	lexMultiname := abc.Source.ConstantPool.Multinames[lex.Operands[0]]
+	lexName := abc.Source.ConstantPool.Strings[lexMultiname.Name]
 	if lexName != "BooleanByteWrapper" {
 		return nil, nil
 	}
@@ -380,12 +414,12 @@ func handleBBWProp(b *builder, class as3.Class, fields map[string]*Field, instrs
 	position := uint(push.Operands[0])
 
 	getProp := instrs[4]
-	propMultiname := b.abcFile.Source.ConstantPool.Multinames[getProp.Operands[0]]
-	prop := b.abcFile.Source.ConstantPool.Strings[propMultiname.Name]
+	propMultiname := abc.Source.ConstantPool.Multinames[getProp.Operands[0]]
+	prop := abc.Source.ConstantPool.Strings[propMultiname.Name]
 
 	field, ok := fields[prop]
 	if !ok || field.Type != "Boolean" {
-		return nil, fmt.Errorf("%v.%v: %v usage of BooleanByteWrapper on non boolean field", class.Namespace, class.Name, prop)
+		return nil, newExtractError(class.Name, class.Namespace, prop, ErrExtractBBWNotBoolean)
 	}
 
 	field.UseBBW = true
@@ -393,100 +427,195 @@ func handleBBWProp(b *builder, class as3.Class, fields map[string]*Field, instrs
 	return field, nil
 }
 
-func (b *builder) extractSerializeMethods(class as3.Class, m as3.Method, fields map[string]*Field) error {
-	checkPattern := func(instrs []bytecode.Instr, pattern []string) bool {
-		if len(pattern) > len(instrs) {
-			return false
-		}
-		for i, str := range pattern {
-			if !strings.HasPrefix(instrs[i].Model.Name, str) {
-				return false
-			}
-		}
-		return true
+// bbwBoxes follows the BooleanByteWrapper bytes of a serialize method. The
+// flags are set on a local register which is then written with
+//
+//	getlocal output, getlocal box, callpropvoid writeByte
+type bbwBoxes struct {
+	open   map[uint32]int // open maps the register of an unwritten byte to its group
+	groups []BBWGroup
+}
+
+// flag adds the field set by the BooleanByteWrapper pattern matched at instrs
+func (x *bbwBoxes) flag(instrs []bytecode.Instr, f *Field) {
+	reg, ok := localIndex(instrs[1], "getlocal")
+	if !ok {
+		return
+	}
+	g, ok := x.open[reg]
+	if !ok {
+		g = len(x.groups)
+		x.groups = append(x.groups, BBWGroup{Register: reg, Position: -1})
+		x.open[reg] = g
 	}
+	x.groups[g].Members = append(x.groups[g].Members, BBWMember{f.Name, f.BBWPosition})
+}
 
-	type pattern struct {
-		Fn      func(*builder, as3.Class, map[string]*Field, []bytecode.Instr, *Field) (*Field, error)
-		Pattern []string
+// writeBox reports whether instrs write an open byte and records its position
+func (b *builder) writeBox(abc *as3.AbcFile, x *bbwBoxes, instrs []bytecode.Instr, position int) bool {
+	if len(instrs) < 2 || instrs[1].Model.Name != "callpropvoid" {
+		return false
 	}
+	reg, ok := localIndex(instrs[0], "getlocal")
+	if !ok {
+		return false
+	}
+	g, ok := x.open[reg]
+	if !ok {
+		return false
+	}
+	if name, _ := b.operandName(abc, instrs[1]); name != "writeByte" {
+		return false
+	}
+	x.groups[g].Position = position
+	delete(x.open, reg)
+	return true
+}
 
-	// These must be sorted by pattern length to be sure to not miss any pattern
-	patterns := []pattern{
-		{handleVecPropDynamicLen, []string{"getlocal", "increment", "convert", "setlocal", "getlocal", "pushbyte", "iflt"}},
-		{handleVecTypeManagerProp, []string{"getproperty", "getlocal", "getproperty", "getlex", "astypelate", "callproperty"}},
-		{handleBBWProp, []string{"getlex", "getlocal", "pushbyte", "getlocal", "getproperty", "callproperty"}},
-		{handleVecScalarProp, []string{"getproperty", "getlocal", "getproperty", "callpropvoid"}},
-		{handleVecPropLength, []string{"getproperty", "getproperty", "callpropvoid"}},
-		{handleSimpleProp, []string{"getproperty", "callpropvoid"}},
-		{handleTypeManagerProp, []string{"getproperty", "callproperty", "callpropvoid"}},
-		{handleGetProperty, []string{"getproperty"}},
+// indexBoxes sets the Index of each group once the fields are sorted
+func indexBoxes(boxes []BBWGroup, fields []Field) {
+	index := map[string]int{}
+	for i, f := range fields {
+		index[f.Name] = i
 	}
+	for i := range boxes {
+		boxes[i].Index = index[boxes[i].Members[0].Field]
+	}
+}
 
-	instrs := m.BodyInfo.Instructions
-	instrLen := len(m.BodyInfo.Instructions)
+// sortFields sorts the fields by Order. Fields that were not ordered by
+// extractSerializeMethods keep their trait order after the others.
+func sortFields(fields []Field) {
+	next := 0
+	for _, f := range fields {
+		if f.Order >= next {
+			next = f.Order + 1
+		}
+	}
+	for i := range fields {
+		if fields[i].Order < 0 {
+			fields[i].Order = next
+			next++
+		}
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Order < fields[j].Order })
+}
+
+// extractSerializeMethods fills the fields written by the serialize method and
+// reports which instructions were consumed by a pattern. Patterns are tried by
+// priority at each instruction until one returns a field. When the handler of
+// a built-in pattern returns nil, its instructions are skipped and the
+// following patterns are tried after them. Patterns added by RegisterPattern
+// leave the instructions to the following patterns instead. The Order of a
+// field is set the first time a pattern returns it. The BooleanByteWrapper
+// bytes are returned in the order their first flag is set.
+func (b *builder) extractSerializeMethods(abc *as3.AbcFile, class as3.Class, instrs []bytecode.Instr, fields map[string]*Field) ([]bool, []BBWGroup, error) {
+	patterns := registeredPatterns()
+	order := 0
+
+	consumed := make([]bool, len(instrs))
+	boxes := &bbwBoxes{open: map[uint32]int{}}
 	var last *Field
-	for i := 0; i < instrLen; {
+	for i := 0; i < len(instrs); {
+		if b.writeBox(abc, boxes, instrs[i:], order) {
+			i += 2
+			continue
+		}
 		var f *Field
-		var err error
 		for _, p := range patterns {
-			if checkPattern(instrs[i:], p.Pattern) {
-				f, err = p.Fn(b, class, fields, instrs[i:], last)
-				if err != nil {
-					return err
+			if !p.match(instrs[i:]) {
+				continue
+			}
+			var err error
+			f, err = p.Handler(abc, class, fields, instrs[i:], last)
+			if err != nil {
+				if e, ok := err.(*ExtractError); ok {
+					e.Instruction = i
+				}
+				return consumed, boxes.groups, err
+			}
+			if f != nil {
+				if p.Name == bbwPattern {
+					boxes.flag(instrs[i:], f)
 				}
-				i += len(p.Pattern)
+				// the property pattern only tells which field the
+				// following instructions are about, they are not consumed
+				// until a write is recognized
+				if p.Name != propertyPattern {
+					for j := range p.Instrs {
+						consumed[i+j] = true
+					}
+				}
+				i += len(p.Instrs)
+				break
+			}
+			if p.builtin {
+				i += len(p.Instrs)
 			}
 		}
 		if f == nil {
 			i++
-		} else {
-			last = f
+			continue
 		}
+		if f.Order < 0 {
+			f.Order = order
+			order++
+		}
+		last = f
 	}
-	return nil
+	return consumed, boxes.groups, nil
 }
 
-func (b *builder) ExtractVersion() (Version, error) {
+const (
+	buildInfosNamespace = "com.ankamagames.dofus"
+	buildInfosName      = "BuildInfos"
+)
+
+func (b *builder) ExtractVersion(abc *as3.AbcFile) (Version, error) {
 	findBuildInfos := func() *as3.Class {
-		for _, c := range b.abcFile.Classes {
-			if c.Namespace == "com.ankamagames.dofus" && c.Name == "BuildInfos" {
+		for _, c := range abc.Classes {
+			if c.Namespace == buildInfosNamespace && c.Name == buildInfosName {
 				return &c
 			}
 		}
 		return nil
 	}
 
-	extractValue := func(i bytecode.Instr) (uint, error) {
+	buildInfos := findBuildInfos()
+	if buildInfos == nil {
+		return Version{}, ErrExtractNoBuildInfos
+	}
+
+	m := abc.Methods[buildInfos.ClassInfo.CInit]
+	if err := m.BodyInfo.Disassemble(); err != nil {
+		return Version{}, newExtractError(buildInfos.Name, buildInfos.Namespace, "", err)
+	}
+
+	instrs := m.BodyInfo.Instructions
+
+	extractValue := func(idx int) (uint, error) {
+		i := instrs[idx]
 		if i.Model.Name == "pushbyte" {
 			return uint(i.Operands[0]), nil
 		} else if i.Model.Name == "pushint" {
-			v := b.abcFile.Source.ConstantPool.Integers[i.Operands[0]]
+			v := abc.Source.ConstantPool.Integers[i.Operands[0]]
 			return uint(v), nil
 		}
-		return 0, fmt.Errorf("%v instruction detected when extracting version", i.Model.Name)
+		e := newExtractError(buildInfos.Name, buildInfos.Namespace, "", ErrExtractVersionInstr)
+		e.Instruction = idx
+		return 0, e
 	}
 
-	extractFromString := func(x string) (uint, error) {
+	extractFromString := func(x string, idx int) (uint, error) {
 		n, err := strconv.Atoi(x)
 		if err != nil {
-			return 0, err
+			e := newExtractError(buildInfos.Name, buildInfos.Namespace, "", err)
+			e.Instruction = idx
+			return 0, e
 		}
 		return uint(n), nil
 	}
 
-	buildInfos := findBuildInfos()
-	if buildInfos == nil {
-		return Version{}, ErrExtractNoBuildInfos
-	}
-
-	m := b.abcFile.Methods[buildInfos.ClassInfo.CInit]
-	if err := m.BodyInfo.Disassemble(); err != nil {
-		return Version{}, fmt.Errorf("could not disassemble BuildInfos: %v", err)
-	}
-
-	instrs := m.BodyInfo.Instructions
-
 	// New versions of Dofus uses a new way to format the Version.
 	// public static var VERSION:Version = new Version("2.42.0",BuildTypeEnum.RELEASE,1027565,0);
 
@@ -494,88 +623,88 @@ func (b *builder) ExtractVersion() (Version, error) {
 	var major, minor, release, revision, patch uint
 	var err error
 
-	fmt.Println(len(instrs))
-	fmt.Println(instrs)
 	if instrs[2].Model.Name == "debug" {
-		majMinRelInstr := instrs[5]
-		revInstr := instrs[8]
-		patchInstr := instrs[9]
+		majMinRelIdx := 5
+		majMinRelInstr := instrs[majMinRelIdx]
+		revIdx := 8
+		patchIdx := 9
 
 		strIdx := majMinRelInstr.Operands[0]
 		// string of format "MAJOR.MINOR.RELEASE"
-		majMinRel := strings.Split(b.abcFile.Source.ConstantPool.Strings[strIdx], ".")
-		major, err = extractFromString(majMinRel[0])
+		majMinRel := strings.Split(abc.Source.ConstantPool.Strings[strIdx], ".")
+		major, err = extractFromString(majMinRel[0], majMinRelIdx)
 		if err != nil {
 			return Version{}, err
 		}
-		minor, err = extractFromString(majMinRel[1])
+		minor, err = extractFromString(majMinRel[1], majMinRelIdx)
 		if err != nil {
 			return Version{}, err
 		}
-		release, err = extractFromString(majMinRel[2])
+		release, err = extractFromString(majMinRel[2], majMinRelIdx)
 		if err != nil {
 			return Version{}, err
 		}
-		revision, err = extractValue(revInstr)
+		revision, err = extractValue(revIdx)
 		if err != nil {
 			return Version{}, err
 		}
-		patch, err = extractValue(patchInstr)
+		patch, err = extractValue(patchIdx)
 		if err != nil {
 			return Version{}, err
 		}
 	} else if instrs[4].Model.Name == "pushstring" {
-		majMinRelInstr := instrs[4]
-		revInstr := instrs[7]
-		patchInstr := instrs[8]
+		majMinRelIdx := 4
+		majMinRelInstr := instrs[majMinRelIdx]
+		revIdx := 7
+		patchIdx := 8
 
 		strIdx := majMinRelInstr.Operands[0]
 		// string of format "MAJOR.MINOR.RELEASE"
-		majMinRel := strings.Split(b.abcFile.Source.ConstantPool.Strings[strIdx], ".")
-		major, err = extractFromString(majMinRel[0])
+		majMinRel := strings.Split(abc.Source.ConstantPool.Strings[strIdx], ".")
+		major, err = extractFromString(majMinRel[0], majMinRelIdx)
 		if err != nil {
 			return Version{}, err
 		}
-		minor, err = extractFromString(majMinRel[1])
+		minor, err = extractFromString(majMinRel[1], majMinRelIdx)
 		if err != nil {
 			return Version{}, err
 		}
-		release, err = extractFromString(majMinRel[2])
+		release, err = extractFromString(majMinRel[2], majMinRelIdx)
 		if err != nil {
 			return Version{}, err
 		}
-		revision, err = extractValue(revInstr)
+		revision, err = extractValue(revIdx)
 		if err != nil {
 			return Version{}, err
 		}
-		patch, err = extractValue(patchInstr)
+		patch, err = extractValue(patchIdx)
 		if err != nil {
 			return Version{}, err
 		}
 	} else {
-		majInstr := instrs[4]
-		minInstr := instrs[5]
-		relInstr := instrs[6]
-		revInstr := instrs[14]
-		patchInstr := instrs[17]
+		majIdx := 4
+		minIdx := 5
+		relIdx := 6
+		revIdx := 14
+		patchIdx := 17
 
-		major, err = extractValue(majInstr)
+		major, err = extractValue(majIdx)
 		if err != nil {
 			return Version{}, err
 		}
-		minor, err = extractValue(minInstr)
+		minor, err = extractValue(minIdx)
 		if err != nil {
 			return Version{}, err
 		}
-		release, err = extractValue(relInstr)
+		release, err = extractValue(relIdx)
 		if err != nil {
 			return Version{}, err
 		}
-		revision, err = extractValue(revInstr)
+		revision, err = extractValue(revIdx)
 		if err != nil {
 			return Version{}, err
 		}
-		patch, err = extractValue(patchInstr)
+		patch, err = extractValue(patchIdx)
 		if err != nil {
 			return Version{}, err
 		}
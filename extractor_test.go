@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/kelvyne/as3"
+	"github.com/kelvyne/as3/bytecode"
 	"github.com/kelvyne/swf"
 )
 
@@ -24,13 +25,17 @@ func open(t *testing.T) *as3.AbcFile {
 	if err != nil {
 		t.Error(err)
 	}
-	abc, err := parseAbc(&s)
+	abcs, err := parseAbc(&s, []string{"frame1"})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	return abc
+	return abcs[0]
 }
 
+// maxSafeInteger is the bound checked by the deserialize methods on Number
+// and 64 bits fields, 2^53
+const maxSafeInteger = 9007199254740992
+
 func Test_builder_ExtractClass(t *testing.T) {
 	abc := open(t)
 	simple, _ := abc.GetClassByName("GameFightOptionStateUpdateMessage")
@@ -64,13 +69,16 @@ func Test_builder_ExtractClass(t *testing.T) {
 				"com.ankamagames.dofus.network.messages.game.context.fight",
 				"",
 				[]Field{
-					Field{Name: "fightId", Type: "uint16", WriteMethod: "writeShort", Method: "UInt16"},
-					Field{Name: "teamId", Type: "uint8", WriteMethod: "writeByte", Method: "UInt8"},
-					Field{Name: "option", Type: "uint8", WriteMethod: "writeByte", Method: "UInt8"},
-					Field{Name: "state", Type: "bool", WriteMethod: "writeBoolean", Method: "Boolean"},
+					Field{Name: "fightId", Order: 0, Type: "uint16", WriteMethod: "writeShort", Method: "UInt16", MinOp: "<"},
+					Field{Name: "teamId", Order: 1, Type: "uint8", WriteMethod: "writeByte", Method: "UInt8", MinOp: "<"},
+					Field{Name: "option", Order: 2, Type: "uint8", WriteMethod: "writeByte", Method: "UInt8", MinOp: "<"},
+					Field{Name: "state", Order: 3, Type: "bool", WriteMethod: "writeBoolean", Method: "Boolean"},
 				},
 				5927,
 				false,
+				Hash{},
+				DirectionUnknown,
+				nil,
 			},
 			false,
 		},
@@ -83,12 +91,15 @@ func Test_builder_ExtractClass(t *testing.T) {
 				"",
 				[]Field{
 					Field{
-						Name: "content", Type: "uint8", WriteMethod: "writeByte", Method: "UInt8",
+						Name: "content", Order: 0, Type: "uint8", WriteMethod: "writeByte", Method: "UInt8",
 						IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeVarInt",
 					},
 				},
 				6253,
 				false,
+				Hash{},
+				DirectionUnknown,
+				nil,
 			},
 			false,
 		},
@@ -100,10 +111,13 @@ func Test_builder_ExtractClass(t *testing.T) {
 				"com.ankamagames.dofus.network.messages.connection",
 				"IdentificationSuccessMessage",
 				[]Field{
-					Field{Name: "loginToken", Type: "string", WriteMethod: "writeUTF", Method: "String"},
+					Field{Name: "loginToken", Order: 0, Type: "string", WriteMethod: "writeUTF", Method: "String"},
 				},
 				6209,
 				false,
+				Hash{},
+				DirectionUnknown,
+				nil,
 			},
 			false,
 		},
@@ -115,10 +129,13 @@ func Test_builder_ExtractClass(t *testing.T) {
 				"com.ankamagames.dofus.network.messages.game.character.stats",
 				"",
 				[]Field{
-					Field{Name: "newLevel", Type: "uint8", WriteMethod: "writeByte", Method: "UInt8"},
+					Field{Name: "newLevel", Order: 0, Type: "uint8", WriteMethod: "writeByte", Method: "UInt8", Min: 2, MinOp: "<", Max: 200, MaxOp: ">"},
 				},
 				5670,
 				false,
+				Hash{},
+				DirectionUnknown,
+				nil,
 			},
 			false,
 		},
@@ -130,13 +147,16 @@ func Test_builder_ExtractClass(t *testing.T) {
 				"com.ankamagames.dofus.network.types.web.krosmaster",
 				"",
 				[]Field{
-					Field{Name: "uid", Type: "string", WriteMethod: "writeUTF", Method: "String"},
-					Field{Name: "figure", Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16"},
-					Field{Name: "pedestal", Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16"},
-					Field{Name: "bound", Type: "bool", WriteMethod: "writeBoolean", Method: "Boolean"},
+					Field{Name: "uid", Order: 0, Type: "string", WriteMethod: "writeUTF", Method: "String"},
+					Field{Name: "figure", Order: 1, Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16", MinOp: "<"},
+					Field{Name: "pedestal", Order: 2, Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16", MinOp: "<"},
+					Field{Name: "bound", Order: 3, Type: "bool", WriteMethod: "writeBoolean", Method: "Boolean"},
 				},
 				397,
 				false,
+				Hash{},
+				DirectionUnknown,
+				nil,
 			},
 			false,
 		},
@@ -148,18 +168,21 @@ func Test_builder_ExtractClass(t *testing.T) {
 				"com.ankamagames.dofus.network.messages.connection",
 				"",
 				[]Field{
-					Field{Name: "version", Type: "VersionExtended"},
-					Field{Name: "lang", Type: "string", WriteMethod: "writeUTF", Method: "String"},
-					Field{Name: "credentials", Type: "int8", WriteMethod: "writeByte", Method: "Int8", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeVarInt"},
-					Field{Name: "serverId", Type: "int16", WriteMethod: "writeShort", Method: "Int16"},
-					Field{Name: "autoconnect", Type: "bool", UseBBW: true, BBWPosition: 0},
-					Field{Name: "useCertificate", Type: "bool", UseBBW: true, BBWPosition: 1},
-					Field{Name: "useLoginToken", Type: "bool", UseBBW: true, BBWPosition: 2},
-					Field{Name: "sessionOptionalSalt", Type: "int64", WriteMethod: "writeVarLong", Method: "VarInt64"},
-					Field{Name: "failedAttempts", Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeShort"},
+					Field{Name: "autoconnect", Order: 0, Type: "bool", UseBBW: true, BBWPosition: 0},
+					Field{Name: "useCertificate", Order: 1, Type: "bool", UseBBW: true, BBWPosition: 1},
+					Field{Name: "useLoginToken", Order: 2, Type: "bool", UseBBW: true, BBWPosition: 2},
+					Field{Name: "version", Order: 3, Type: "VersionExtended"},
+					Field{Name: "lang", Order: 4, Type: "string", WriteMethod: "writeUTF", Method: "String"},
+					Field{Name: "credentials", Order: 5, Type: "int8", WriteMethod: "writeByte", Method: "Int8", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeVarInt"},
+					Field{Name: "serverId", Order: 6, Type: "int16", WriteMethod: "writeShort", Method: "Int16"},
+					Field{Name: "sessionOptionalSalt", Order: 7, Type: "int64", WriteMethod: "writeVarLong", Method: "VarInt64", Min: -maxSafeInteger, MinOp: "<", Max: maxSafeInteger, MaxOp: ">"},
+					Field{Name: "failedAttempts", Order: 8, Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeShort", MinOp: "<"},
 				},
 				4,
 				false,
+				Hash{},
+				DirectionUnknown,
+				[]BBWGroup{{0, 2, 3, []BBWMember{{"autoconnect", 0}, {"useCertificate", 1}, {"useLoginToken", 2}}}},
 			},
 			false,
 		},
@@ -171,10 +194,13 @@ func Test_builder_ExtractClass(t *testing.T) {
 				"com.ankamagames.dofus.network.messages.game.character.choice",
 				"",
 				[]Field{
-					Field{Name: "characters", Type: "CharacterBaseInformations", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeShort", UseTypeManager: true},
+					Field{Name: "characters", Order: 0, Type: "CharacterBaseInformations", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeShort", UseTypeManager: true},
 				},
 				6475,
 				false,
+				Hash{},
+				DirectionUnknown,
+				nil,
 			},
 			false,
 		},
@@ -186,12 +212,15 @@ func Test_builder_ExtractClass(t *testing.T) {
 				"com.ankamagames.dofus.network.types.game.context",
 				"",
 				[]Field{
-					Field{Name: "contextualId", Type: "float64", WriteMethod: "writeDouble", Method: "Double"},
-					Field{Name: "look", Type: "EntityLook"},
-					Field{Name: "disposition", Type: "EntityDispositionInformations", UseTypeManager: true},
+					Field{Name: "contextualId", Order: 0, Type: "float64", WriteMethod: "writeDouble", Method: "Double", Min: -maxSafeInteger, MinOp: "<", Max: maxSafeInteger, MaxOp: ">"},
+					Field{Name: "look", Order: 1, Type: "EntityLook"},
+					Field{Name: "disposition", Order: 2, Type: "EntityDispositionInformations", UseTypeManager: true},
 				},
 				150,
 				false,
+				Hash{},
+				DirectionUnknown,
+				nil,
 			},
 			false,
 		},
@@ -203,10 +232,13 @@ func Test_builder_ExtractClass(t *testing.T) {
 				"com.ankamagames.dofus.network.messages.game.alliance",
 				"",
 				[]Field{
-					Field{Name: "targetId", Type: "int64", WriteMethod: "writeVarLong", Method: "VarInt64"},
+					Field{Name: "targetId", Order: 0, Type: "int64", WriteMethod: "writeVarLong", Method: "VarInt64", MinOp: "<", Max: maxSafeInteger, MaxOp: ">"},
 				},
 				6395,
 				false,
+				Hash{},
+				DirectionUnknown,
+				nil,
 			},
 			false,
 		},
@@ -218,17 +250,20 @@ func Test_builder_ExtractClass(t *testing.T) {
 				"com.ankamagames.dofus.network.types.game.context.roleplay",
 				"GameRolePlayActorInformations",
 				[]Field{
-					Field{Name: "staticInfos", Type: "GroupMonsterStaticInformations", UseTypeManager: true},
-					Field{Name: "creationTime", Type: "float64", WriteMethod: "writeDouble", Method: "Double"},
-					Field{Name: "ageBonusRate", Type: "uint32", WriteMethod: "writeInt", Method: "UInt32"},
-					Field{Name: "lootShare", Type: "int8", WriteMethod: "writeByte", Method: "Int8"},
-					Field{Name: "alignmentSide", Type: "int8", WriteMethod: "writeByte", Method: "Int8"},
-					Field{Name: "keyRingBonus", Type: "bool", UseBBW: true, BBWPosition: 0},
-					Field{Name: "hasHardcoreDrop", Type: "bool", UseBBW: true, BBWPosition: 1},
-					Field{Name: "hasAVARewardToken", Type: "bool", UseBBW: true, BBWPosition: 2},
+					Field{Name: "keyRingBonus", Order: 0, Type: "bool", UseBBW: true, BBWPosition: 0},
+					Field{Name: "hasHardcoreDrop", Order: 1, Type: "bool", UseBBW: true, BBWPosition: 1},
+					Field{Name: "hasAVARewardToken", Order: 2, Type: "bool", UseBBW: true, BBWPosition: 2},
+					Field{Name: "staticInfos", Order: 3, Type: "GroupMonsterStaticInformations", UseTypeManager: true},
+					Field{Name: "creationTime", Order: 4, Type: "float64", WriteMethod: "writeDouble", Method: "Double", MinOp: "<", Max: maxSafeInteger, MaxOp: ">"},
+					Field{Name: "ageBonusRate", Order: 5, Type: "uint32", WriteMethod: "writeInt", Method: "UInt32", MinOp: "<"},
+					Field{Name: "lootShare", Order: 6, Type: "int8", WriteMethod: "writeByte", Method: "Int8", Min: -1, MinOp: "<", Max: 8, MaxOp: ">"},
+					Field{Name: "alignmentSide", Order: 7, Type: "int8", WriteMethod: "writeByte", Method: "Int8"},
 				},
 				160,
 				false,
+				Hash{},
+				DirectionUnknown,
+				[]BBWGroup{{0, 2, 3, []BBWMember{{"keyRingBonus", 0}, {"hasHardcoreDrop", 1}, {"hasAVARewardToken", 2}}}},
 			},
 			false,
 		},
@@ -241,12 +276,15 @@ func Test_builder_ExtractClass(t *testing.T) {
 				"",
 				[]Field{
 					Field{
-						Name: "content", Type: "uint8", WriteMethod: "writeByte", Method: "UInt8",
+						Name: "content", Order: 0, Type: "uint8", WriteMethod: "writeByte", Method: "UInt8",
 						IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeVarInt",
 					},
 				},
 				2,
 				false,
+				Hash{},
+				DirectionUnknown,
+				nil,
 			},
 			false,
 		},
@@ -260,6 +298,9 @@ func Test_builder_ExtractClass(t *testing.T) {
 				nil,
 				101,
 				false,
+				Hash{},
+				DirectionUnknown,
+				nil,
 			},
 			false,
 		},
@@ -271,22 +312,23 @@ func Test_builder_ExtractClass(t *testing.T) {
 				"com.ankamagames.dofus.network.messages.game.basic",
 				"",
 				[]Field{
-					Field{Name: "latency", Type: "uint16", WriteMethod: "writeShort", Method: "UInt16"},
-					Field{Name: "sampleCount", Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16"},
-					Field{Name: "max", Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16"},
+					Field{Name: "latency", Order: 0, Type: "uint16", WriteMethod: "writeShort", Method: "UInt16", MinOp: "<", Max: 65535, MaxOp: ">"},
+					Field{Name: "sampleCount", Order: 1, Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16", MinOp: "<"},
+					Field{Name: "max", Order: 2, Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16", MinOp: "<"},
 				},
 				5663,
 				true,
+				Hash{"HASH_FUNCTION", true, HashPositionPayload},
+				DirectionUnknown,
+				nil,
 			},
 			false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			b := &builder{
-				abcFile: abc,
-			}
-			got, err := b.ExtractClass(tt.args.class)
+			b := &builder{}
+			got, err := b.ExtractClass(abc, tt.args.class)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("builder.ExtractClass() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -298,6 +340,78 @@ func Test_builder_ExtractClass(t *testing.T) {
 	}
 }
 
+func Test_sortFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []Field
+		want   []string
+	}{
+		{"ordered", []Field{{Name: "b", Order: 1}, {Name: "a", Order: 0}}, []string{"a", "b"}},
+		{"never written", []Field{{Name: "c", Order: -1}, {Name: "b", Order: 1}, {Name: "d", Order: -1}, {Name: "a", Order: 0}}, []string{"a", "b", "c", "d"}},
+		{"empty", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sortFields(tt.fields)
+			var got []string
+			for i, f := range tt.fields {
+				if f.Order != i {
+					t.Errorf("%v: Order = %v, want %v", f.Name, f.Order, i)
+				}
+				got = append(got, f.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_builder_extractSerializeMethods_BBW(t *testing.T) {
+	names := []string{"", "BooleanByteWrapper", "setFlag", "writeByte", "a", "b", "x"}
+	pool := bytecode.CpoolInfo{
+		Strings:    names,
+		Namespaces: []bytecode.NamespaceInfo{{}, {Kind: bytecode.NamespaceKindPackageNamespace}},
+	}
+	for i := range names {
+		pool.Multinames = append(pool.Multinames, bytecode.MultinameInfo{Kind: bytecode.MultinameKindQName, Name: uint32(i), Namespace: 1})
+	}
+	abc := &as3.AbcFile{Source: &bytecode.AbcFile{ConstantPool: pool}}
+	b := &builder{}
+	instr := func(name string, operands ...uint32) bytecode.Instr {
+		return bytecode.Instr{Model: bytecode.InstrModel{Name: name}, Operands: operands}
+	}
+	flag := func(bit, field uint32) []bytecode.Instr {
+		return []bytecode.Instr{
+			instr("getlex", 1), instr("getlocal2"), instr("pushbyte", bit), instr("getlocal0"),
+			instr("getproperty", field), instr("callproperty", 2, 3), instr("convert_u"), instr("setlocal2"),
+		}
+	}
+	var instrs []bytecode.Instr
+	instrs = append(instrs, instr("pushbyte", 0), instr("setlocal2"))
+	instrs = append(instrs, flag(0, 4)...)
+	instrs = append(instrs, flag(1, 5)...)
+	instrs = append(instrs, instr("getlocal1"), instr("getlocal2"), instr("callpropvoid", 3, 1))
+	instrs = append(instrs, instr("getlocal1"), instr("getlocal0"), instr("getproperty", 6), instr("callpropvoid", 3, 1))
+
+	fields := map[string]*Field{
+		"a": {Name: "a", Type: "Boolean", Order: -1},
+		"b": {Name: "b", Type: "Boolean", Order: -1},
+		"x": {Name: "x", Type: "int", Order: -1},
+	}
+	_, boxes, err := b.extractSerializeMethods(abc, as3.Class{}, instrs, fields)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	want := []BBWGroup{{0, 2, 2, []BBWMember{{"a", 0}, {"b", 1}}}}
+	if !reflect.DeepEqual(boxes, want) {
+		t.Errorf("builder.extractSerializeMethods() boxes = %v, want %v", boxes, want)
+	}
+	if fields["x"].Order != 2 || fields["x"].WriteMethod != "writeByte" {
+		t.Errorf("x = %+v, want Order 2 written with writeByte", *fields["x"])
+	}
+}
+
 func Test_builder_ExtractEnum(t *testing.T) {
 	abc := open(t)
 	simple, _ := abc.GetClassByName("AccessoryPreviewErrorEnum")
@@ -321,9 +435,9 @@ func Test_builder_ExtractEnum(t *testing.T) {
 			Enum{
 				"AccessoryPreviewErrorEnum",
 				[]EnumValue{
-					{"PREVIEW_ERROR", 0},
-					{"PREVIEW_COOLDOWN", 1},
-					{"PREVIEW_BAD_ITEM", 2},
+					{"PREVIEW_ERROR", Value{Type: "int32", Int: 0}},
+					{"PREVIEW_COOLDOWN", Value{Type: "int32", Int: 1}},
+					{"PREVIEW_BAD_ITEM", Value{Type: "int32", Int: 2}},
 				},
 			},
 			false,
@@ -334,12 +448,12 @@ func Test_builder_ExtractEnum(t *testing.T) {
 			Enum{
 				"AlignmentSideEnum",
 				[]EnumValue{
-					{"ALIGNMENT_UNKNOWN", -2},
-					{"ALIGNMENT_WITHOUT", -1},
-					{"ALIGNMENT_NEUTRAL", 0},
-					{"ALIGNMENT_ANGEL", 1},
-					{"ALIGNMENT_EVIL", 2},
-					{"ALIGNMENT_MERCENARY", 3},
+					{"ALIGNMENT_UNKNOWN", Value{Type: "int32", Int: -2}},
+					{"ALIGNMENT_WITHOUT", Value{Type: "int32", Int: -1}},
+					{"ALIGNMENT_NEUTRAL", Value{Type: "int32", Int: 0}},
+					{"ALIGNMENT_ANGEL", Value{Type: "int32", Int: 1}},
+					{"ALIGNMENT_EVIL", Value{Type: "int32", Int: 2}},
+					{"ALIGNMENT_MERCENARY", Value{Type: "int32", Int: 3}},
 				},
 			},
 			false,
@@ -347,10 +461,8 @@ func Test_builder_ExtractEnum(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			b := &builder{
-				abcFile: abc,
-			}
-			got, err := b.ExtractEnum(tt.args.class)
+			b := &builder{}
+			got, err := b.ExtractEnum(abc, tt.args.class)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("builder.ExtractEnum() error = %v, wantErr %v", err, tt.wantErr)
 				return
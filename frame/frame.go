@@ -0,0 +1,128 @@
+// Package frame splits a Dofus 2 TCP stream into message frames and builds
+// frames back.
+//
+// A frame starts with a big-endian short holding the protocol id shifted by
+// two bits and, in the two low bits, the number of bytes used by the payload
+// length. Frames sent by the client then carry an unsigned int instance id.
+// The payload length and the payload follow.
+package frame
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrIncomplete means that the data does not hold a whole frame yet
+var ErrIncomplete = errors.New("incomplete frame")
+
+// ErrProtocolIDTooLarge means that a protocol id does not fit in 14 bits
+var ErrProtocolIDTooLarge = errors.New("protocol id too large")
+
+// ErrPayloadTooLarge means that a payload length does not fit in 3 bytes
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// MaxProtocolID is the largest protocol id that fits in a header
+const MaxProtocolID = 1<<14 - 1
+
+// MaxPayloadLen is the largest payload length that fits in a header
+const MaxPayloadLen = 1<<24 - 1
+
+// Direction tells who sent a frame, which changes the header layout
+type Direction int
+
+const (
+	// ClientToServer frames carry an instance id
+	ClientToServer Direction = iota
+	// ServerToClient frames do not carry an instance id
+	ServerToClient
+)
+
+func (d Direction) String() string {
+	switch d {
+	case ClientToServer:
+		return "client->server"
+	case ServerToClient:
+		return "server->client"
+	}
+	return fmt.Sprintf("Direction(%d)", int(d))
+}
+
+// Frame is a single message on the wire
+type Frame struct {
+	ProtocolID uint16
+	InstanceID uint32 // InstanceID is only used by ClientToServer frames
+	Payload    []byte
+}
+
+func lengthType(n int) uint16 {
+	switch {
+	case n > 0xFFFF:
+		return 3
+	case n > 0xFF:
+		return 2
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func headerLen(d Direction, lenType uint16) int {
+	n := 2 + int(lenType)
+	if d == ClientToServer {
+		n += 4
+	}
+	return n
+}
+
+// Parse parses the frame at the start of data and returns the number of bytes
+// it uses. It returns ErrIncomplete if data is too short. The payload aliases
+// data.
+func Parse(data []byte, d Direction) (Frame, int, error) {
+	if len(data) < 2 {
+		return Frame{}, 0, ErrIncomplete
+	}
+	hi := uint16(data[0])<<8 | uint16(data[1])
+	lenType := hi & 3
+	n := headerLen(d, lenType)
+	if len(data) < n {
+		return Frame{}, 0, ErrIncomplete
+	}
+
+	f := Frame{ProtocolID: hi >> 2}
+	off := 2
+	if d == ClientToServer {
+		f.InstanceID = uint32(data[2])<<24 | uint32(data[3])<<16 | uint32(data[4])<<8 | uint32(data[5])
+		off += 4
+	}
+	var length int
+	for _, b := range data[off:n] {
+		length = length<<8 | int(b)
+	}
+	if len(data) < n+length {
+		return Frame{}, 0, ErrIncomplete
+	}
+	f.Payload = data[n : n+length]
+	return f, n + length, nil
+}
+
+// Append appends the encoded frame f to dst
+func Append(dst []byte, f Frame, d Direction) ([]byte, error) {
+	if f.ProtocolID > MaxProtocolID {
+		return dst, fmt.Errorf("%v: %w", f.ProtocolID, ErrProtocolIDTooLarge)
+	}
+	if len(f.Payload) > MaxPayloadLen {
+		return dst, fmt.Errorf("%v bytes: %w", len(f.Payload), ErrPayloadTooLarge)
+	}
+
+	lenType := lengthType(len(f.Payload))
+	hi := f.ProtocolID<<2 | lenType
+	dst = append(dst, byte(hi>>8), byte(hi))
+	if d == ClientToServer {
+		id := f.InstanceID
+		dst = append(dst, byte(id>>24), byte(id>>16), byte(id>>8), byte(id))
+	}
+	for i := int(lenType) - 1; i >= 0; i-- {
+		dst = append(dst, byte(len(f.Payload)>>uint(8*i)))
+	}
+	return append(dst, f.Payload...), nil
+}
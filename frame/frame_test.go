@@ -0,0 +1,106 @@
+package frame
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestAppendParse(t *testing.T) {
+	tests := []struct {
+		name string
+		f    Frame
+		d    Direction
+		want []byte
+	}{
+		{
+			"server empty",
+			Frame{ProtocolID: 1, Payload: []byte{}},
+			ServerToClient,
+			[]byte{0x00, 0x04},
+		},
+		{
+			"server short",
+			Frame{ProtocolID: 6253, Payload: []byte{0xAA, 0xBB}},
+			ServerToClient,
+			[]byte{0x61, 0xB5, 0x02, 0xAA, 0xBB},
+		},
+		{
+			"client",
+			Frame{ProtocolID: 4, InstanceID: 7, Payload: []byte{0x01}},
+			ClientToServer,
+			[]byte{0x00, 0x11, 0x00, 0x00, 0x00, 0x07, 0x01, 0x01},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Append(nil, tt.f, tt.d)
+			if err != nil {
+				t.Fatalf("Append() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("Append() = %x, want %x", got, tt.want)
+			}
+
+			f, n, err := Parse(append(got, 0xFF), tt.d)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if n != len(tt.want) {
+				t.Errorf("Parse() n = %v, want %v", n, len(tt.want))
+			}
+			if !reflect.DeepEqual(f, tt.f) {
+				t.Errorf("Parse() = %v, want %v", f, tt.f)
+			}
+
+			if _, _, err := Parse(got[:len(got)-1], tt.d); err != ErrIncomplete {
+				t.Errorf("Parse() on truncated frame error = %v, want ErrIncomplete", err)
+			}
+		})
+	}
+}
+
+func TestAppend_Errors(t *testing.T) {
+	if _, err := Append(nil, Frame{ProtocolID: MaxProtocolID + 1}, ServerToClient); !errors.Is(err, ErrProtocolIDTooLarge) {
+		t.Errorf("Append() error = %v, want ErrProtocolIDTooLarge", err)
+	}
+	if _, err := Append(nil, Frame{Payload: make([]byte, MaxPayloadLen+1)}, ServerToClient); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Append() error = %v, want ErrPayloadTooLarge", err)
+	}
+}
+
+func TestReaderWriter(t *testing.T) {
+	frames := []Frame{
+		{ProtocolID: 1, InstanceID: 1, Payload: []byte{}},
+		{ProtocolID: 6253, InstanceID: 2, Payload: make([]byte, 300)},
+		{ProtocolID: 2, InstanceID: 3, Payload: make([]byte, 70000)},
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf, ClientToServer)
+	for _, f := range frames {
+		if err := w.WriteFrame(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewReader(&buf, ClientToServer)
+	for i, want := range frames {
+		got, err := r.ReadFrame()
+		if err != nil {
+			t.Fatalf("ReadFrame() #%v error = %v", i, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadFrame() #%v = %v, want %v", i, got.ProtocolID, want.ProtocolID)
+		}
+	}
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Errorf("ReadFrame() at end error = %v, want io.EOF", err)
+	}
+
+	r = NewReader(bytes.NewReader([]byte{0x00, 0x05, 0x01}), ServerToClient)
+	if _, err := r.ReadFrame(); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadFrame() on truncated stream error = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
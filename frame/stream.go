@@ -0,0 +1,74 @@
+package frame
+
+import (
+	"bufio"
+	"io"
+)
+
+// Reader reads frames from a byte stream
+type Reader struct {
+	r *bufio.Reader
+	d Direction
+}
+
+// NewReader returns a Reader reading frames sent in direction d from r
+func NewReader(r io.Reader, d Direction) *Reader {
+	return &Reader{bufio.NewReader(r), d}
+}
+
+// ReadFrame reads the next frame. It returns io.EOF if the stream ends
+// between two frames and io.ErrUnexpectedEOF if it ends inside a frame.
+func (r *Reader) ReadFrame() (Frame, error) {
+	var hi [2]byte
+	if _, err := io.ReadFull(r.r, hi[:]); err != nil {
+		return Frame{}, err
+	}
+	lenType := uint16(hi[1]) & 3
+	header := make([]byte, headerLen(r.d, lenType))
+	copy(header, hi[:])
+	if _, err := io.ReadFull(r.r, header[2:]); err != nil {
+		return Frame{}, noEOF(err)
+	}
+
+	var length int
+	for _, b := range header[len(header)-int(lenType):] {
+		length = length<<8 | int(b)
+	}
+	data := make([]byte, len(header)+length)
+	copy(data, header)
+	if _, err := io.ReadFull(r.r, data[len(header):]); err != nil {
+		return Frame{}, noEOF(err)
+	}
+
+	f, _, err := Parse(data, r.d)
+	return f, err
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Writer writes frames to a byte stream
+type Writer struct {
+	w   io.Writer
+	d   Direction
+	buf []byte
+}
+
+// NewWriter returns a Writer writing frames sent in direction d to w
+func NewWriter(w io.Writer, d Direction) *Writer {
+	return &Writer{w: w, d: d}
+}
+
+// WriteFrame writes f
+func (w *Writer) WriteFrame(f Frame) error {
+	var err error
+	if w.buf, err = Append(w.buf[:0], f, w.d); err != nil {
+		return err
+	}
+	_, err = w.w.Write(w.buf)
+	return err
+}
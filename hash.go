@@ -0,0 +1,159 @@
+package d2protocolparser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kelvyne/as3"
+	"github.com/kelvyne/as3/bytecode"
+)
+
+// HashPosition tells where the pack method of a message appends its hash
+type HashPosition int
+
+const (
+	// HashPositionUnknown is used when the call to the hash function is not
+	// recognized
+	HashPositionUnknown HashPosition = iota
+	// HashPositionPayload hashes are appended to the payload before
+	// writePacket, the payload length of the frame counts them
+	HashPositionPayload
+	// HashPositionPacket hashes are appended after writePacket, outside of
+	// the frame
+	HashPositionPacket
+)
+
+func (p HashPosition) String() string {
+	switch p {
+	case HashPositionUnknown:
+		return "unknown"
+	case HashPositionPayload:
+		return "payload"
+	case HashPositionPacket:
+		return "packet"
+	}
+	return fmt.Sprintf("HashPosition(%d)", int(p))
+}
+
+// Hash describes the hash the pack method of a message appends. The client
+// sets the hash function at runtime, so the length of the hash is not part of
+// the protocol.
+type Hash struct {
+	Function string // Function is the static variable holding the hash function
+	// Payload is set when the function is given the ByteArray the message is
+	// serialized to, the hash then covers the whole payload
+	Payload  bool
+	Position HashPosition
+}
+
+const hashFunction = "HASH_FUNCTION"
+
+// operandName returns the name of the multiname an instruction refers to
+func (b *builder) operandName(abc *as3.AbcFile, instr bytecode.Instr) (string, bool) {
+	if !multinameOperandInstrs[instr.Model.Name] || len(instr.Operands) == 0 {
+		return "", false
+	}
+	pool := abc.Source.ConstantPool
+	multiname := pool.Multinames[instr.Operands[0]]
+	if multiname.Kind != bytecode.MultinameKindQName {
+		return "", false
+	}
+	return pool.Strings[multiname.Name], true
+}
+
+func isCall(instr bytecode.Instr) bool {
+	switch instr.Model.Name {
+	case "call", "callproperty", "callpropvoid", "callproplex":
+		return true
+	}
+	return false
+}
+
+// hashCall returns the call of the hash function referenced at i. The null
+// check, getlex HASH_FUNCTION, pushnull, ifeq, is not a call.
+func hashCall(instrs []bytecode.Instr, i int) (int, bool) {
+	for j := i; j < len(instrs); j++ {
+		if isCall(instrs[j]) {
+			return j, true
+		}
+		if strings.HasPrefix(instrs[j].Model.Name, "if") || strings.HasPrefix(instrs[j].Model.Name, "jump") {
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// extractHash extracts the hashing procedure of the pack method of a class.
+// Hashed messages pack themselves as
+//
+//	data = new ByteArray()
+//	this.serialize(new CustomDataWrapper(data))
+//	if (HASH_FUNCTION != null) HASH_FUNCTION(data)
+//	writePacket(output, this.getMessageId(), data)
+//
+// The zero Hash is returned for classes whose pack method does not refer to
+// HASH_FUNCTION.
+func (b *builder) extractHash(abc *as3.AbcFile, class as3.Class) (Hash, error) {
+	var pack *as3.Method
+	for _, m := range class.InstanceTraits.Methods {
+		if m.Name == "pack" {
+			pack = &abc.Methods[m.Source.Method]
+			break
+		}
+	}
+	if pack == nil {
+		return Hash{}, nil
+	}
+	if err := pack.BodyInfo.Disassemble(); err != nil {
+		return Hash{}, err
+	}
+	return b.packHash(abc, pack.BodyInfo.Instructions), nil
+}
+
+func (b *builder) packHash(abc *as3.AbcFile, instrs []bytecode.Instr) Hash {
+	var hash Hash
+	serialized, hashed := int64(-1), int64(-1)
+	hashAt, packetAt := -1, -1
+	for i, instr := range instrs {
+		name, ok := b.operandName(abc, instr)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == hashFunction:
+			hash.Function = name
+			if hashAt != -1 {
+				break
+			}
+			if j, ok := hashCall(instrs, i); ok && j > 0 {
+				hashAt = j
+				if reg, ok := localIndex(instrs[j-1], "getlocal"); ok {
+					hashed = int64(reg)
+				}
+			}
+		case strings.HasPrefix(name, "serialize") && isCall(instr):
+			// the ByteArray is the last local pushed before the call,
+			// getlocal0 pushes this
+			for j := i - 1; j >= 0; j-- {
+				if reg, ok := localIndex(instrs[j], "getlocal"); ok && reg != 0 {
+					serialized = int64(reg)
+					break
+				}
+			}
+		case name == "writePacket" && isCall(instr):
+			packetAt = i
+		}
+	}
+	if hash.Function == "" || hashAt == -1 {
+		return hash
+	}
+	hash.Payload = hashed != -1 && hashed == serialized
+	if packetAt != -1 {
+		if hashAt < packetAt {
+			hash.Position = HashPositionPayload
+		} else {
+			hash.Position = HashPositionPacket
+		}
+	}
+	return hash
+}
@@ -0,0 +1,76 @@
+package d2protocolparser
+
+import (
+	"testing"
+
+	"github.com/kelvyne/as3"
+	"github.com/kelvyne/as3/bytecode"
+)
+
+func TestHashPosition_String(t *testing.T) {
+	tests := []struct {
+		p    HashPosition
+		want string
+	}{
+		{HashPositionUnknown, "unknown"},
+		{HashPositionPayload, "payload"},
+		{HashPositionPacket, "packet"},
+		{HashPosition(9), "HashPosition(9)"},
+	}
+	for _, tt := range tests {
+		if got := tt.p.String(); got != tt.want {
+			t.Errorf("HashPosition.String() = %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func Test_builder_packHash(t *testing.T) {
+	names := []string{"", "HASH_FUNCTION", "serialize", "writePacket", "CustomDataWrapper", "getMessageId"}
+	pool := bytecode.CpoolInfo{Strings: names}
+	for i := range names {
+		pool.Multinames = append(pool.Multinames, bytecode.MultinameInfo{Kind: bytecode.MultinameKindQName, Name: uint32(i)})
+	}
+	abc := &as3.AbcFile{Source: &bytecode.AbcFile{ConstantPool: pool}}
+	b := &builder{}
+	instr := func(name string, operands ...uint32) bytecode.Instr {
+		return bytecode.Instr{Model: bytecode.InstrModel{Name: name}, Operands: operands}
+	}
+	serialize := []bytecode.Instr{
+		instr("getlocal0"), instr("findpropstrict", 4), instr("getlocal2"),
+		instr("constructprop", 4, 1), instr("callpropvoid", 2, 1),
+	}
+	nullCheck := []bytecode.Instr{instr("getlex", 1), instr("pushnull"), instr("ifeq", 12)}
+	hash := func(local string) []bytecode.Instr {
+		return []bytecode.Instr{instr("getlex", 1), instr("getglobalscope"), instr(local), instr("call", 1)}
+	}
+	writePacket := []bytecode.Instr{
+		instr("getlocal0"), instr("getlocal1"), instr("getlocal0"), instr("callproperty", 5, 0),
+		instr("getlocal2"), instr("callpropvoid", 3, 3),
+	}
+	concat := func(parts ...[]bytecode.Instr) []bytecode.Instr {
+		var instrs []bytecode.Instr
+		for _, p := range parts {
+			instrs = append(instrs, p...)
+		}
+		return instrs
+	}
+
+	tests := []struct {
+		name   string
+		instrs []bytecode.Instr
+		want   Hash
+	}{
+		{"not hashed", concat(serialize, writePacket), Hash{}},
+		{"payload", concat(serialize, nullCheck, hash("getlocal2"), writePacket), Hash{"HASH_FUNCTION", true, HashPositionPayload}},
+		{"packet", concat(serialize, writePacket, nullCheck, hash("getlocal2")), Hash{"HASH_FUNCTION", true, HashPositionPacket}},
+		{"other local", concat(serialize, hash("getlocal3"), writePacket), Hash{"HASH_FUNCTION", false, HashPositionPayload}},
+		{"no call", concat(serialize, nullCheck, writePacket), Hash{"HASH_FUNCTION", false, HashPositionUnknown}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := b.packHash(abc, tt.instrs); got != tt.want {
+				t.Errorf("builder.packHash() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package d2protocolparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownParent means that the parent of a class is not a message or a
+// type of the Protocol
+var ErrUnknownParent = errors.New("unknown parent class")
+
+// ErrCyclicParent means that a class is its own ancestor
+var ErrCyclicParent = errors.New("cyclic class inheritance")
+
+// ClassByName returns the message or the type named name
+func (p *Protocol) ClassByName(name string) (*Class, bool) {
+	for i := range p.Messages {
+		if p.Messages[i].Name == name {
+			return &p.Messages[i], true
+		}
+	}
+	for i := range p.Types {
+		if p.Types[i].Name == name {
+			return &p.Types[i], true
+		}
+	}
+	return nil, false
+}
+
+// Parent returns the parent of c, or nil when c has no parent
+func (p *Protocol) Parent(c *Class) (*Class, error) {
+	if c.Parent == "" {
+		return nil, nil
+	}
+	parent, ok := p.ClassByName(c.Parent)
+	if !ok {
+		return nil, newError(ErrUnknownParent, fmt.Sprintf("%v extends %v", c.Name, c.Parent))
+	}
+	return parent, nil
+}
+
+// Hierarchy returns the ancestors of c followed by c, the root first, which is
+// the order the serialize methods write their fields in since each one starts
+// with super.serializeAs_
+func (p *Protocol) Hierarchy(c *Class) ([]*Class, error) {
+	chain := []*Class{c}
+	seen := map[string]bool{c.Name: true}
+	for cur := c; cur.Parent != ""; {
+		parent, err := p.Parent(cur)
+		if err != nil {
+			return nil, err
+		}
+		if seen[parent.Name] {
+			var names []string
+			for i := len(chain) - 1; i >= 0; i-- {
+				names = append(names, chain[i].Name)
+			}
+			return nil, newError(ErrCyclicParent, strings.Join(append(names, parent.Name), " extends "))
+		}
+		seen[parent.Name] = true
+		chain = append([]*Class{parent}, chain...)
+		cur = parent
+	}
+	return chain, nil
+}
+
+// FlattenedFields returns the fields of the ancestors of c followed by the
+// fields of c, in the order they are serialized
+func (p *Protocol) FlattenedFields(c *Class) ([]Field, error) {
+	chain, err := p.Hierarchy(c)
+	if err != nil {
+		return nil, err
+	}
+	var fields []Field
+	for _, class := range chain {
+		fields = append(fields, class.Fields...)
+	}
+	return fields, nil
+}
+
+// Subclasses returns the messages and types that inherit from the class named
+// name, directly or not, in Protocol order. Classes with a missing or cyclic
+// parent chain are only listed below the ancestors that could be resolved.
+func (p *Protocol) Subclasses(name string) []*Class {
+	var subclasses []*Class
+	add := func(classes []Class) {
+		for i := range classes {
+			c := &classes[i]
+			seen := map[string]bool{c.Name: true}
+			for cur := c; cur.Parent != "" && !seen[cur.Parent]; {
+				if cur.Parent == name {
+					subclasses = append(subclasses, c)
+					break
+				}
+				seen[cur.Parent] = true
+				parent, ok := p.ClassByName(cur.Parent)
+				if !ok {
+					break
+				}
+				cur = parent
+			}
+		}
+	}
+	add(p.Messages)
+	add(p.Types)
+	return subclasses
+}
@@ -0,0 +1,108 @@
+package d2protocolparser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func inheritanceProtocol() *Protocol {
+	return &Protocol{
+		Messages: []Class{
+			{Name: "IdentificationSuccessMessage", Fields: []Field{{Name: "login"}}},
+			{Name: "IdentificationSuccessWithLoginTokenMessage", Parent: "IdentificationSuccessMessage", Fields: []Field{{Name: "loginToken"}}},
+			{Name: "OrphanMessage", Parent: "MissingMessage"},
+			{Name: "CycleAMessage", Parent: "CycleBMessage"},
+			{Name: "CycleBMessage", Parent: "CycleAMessage"},
+		},
+		Types: []Class{
+			{Name: "GameContextActorInformations", Fields: []Field{{Name: "contextualId"}, {Name: "look"}}},
+			{Name: "GameRolePlayActorInformations", Parent: "GameContextActorInformations"},
+			{Name: "GameRolePlayNamedActorInformations", Parent: "GameRolePlayActorInformations", Fields: []Field{{Name: "name"}}},
+			{Name: "GameFightFighterInformations", Parent: "GameContextActorInformations", Fields: []Field{{Name: "wave"}}},
+		},
+	}
+}
+
+func fieldNames(fields []Field) []string {
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestProtocol_FlattenedFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		class   string
+		want    []string
+		wantErr error
+	}{
+		{"root", "GameContextActorInformations", []string{"contextualId", "look"}, nil},
+		{"child", "IdentificationSuccessWithLoginTokenMessage", []string{"login", "loginToken"}, nil},
+		{"grandchild", "GameRolePlayNamedActorInformations", []string{"contextualId", "look", "name"}, nil},
+		{"missing parent", "OrphanMessage", nil, ErrUnknownParent},
+		{"cyclic parent", "CycleAMessage", nil, ErrCyclicParent},
+	}
+	p := inheritanceProtocol()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ok := p.ClassByName(tt.class)
+			if !ok {
+				t.Fatalf("%v not found", tt.class)
+			}
+			got, err := p.FlattenedFields(c)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Protocol.FlattenedFields() error = %v, want %v", err, tt.wantErr)
+			}
+			if names := fieldNames(got); !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("Protocol.FlattenedFields() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtocol_Hierarchy(t *testing.T) {
+	p := inheritanceProtocol()
+	c, _ := p.ClassByName("GameRolePlayNamedActorInformations")
+	chain, err := p.Hierarchy(c)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	var got []string
+	for _, c := range chain {
+		got = append(got, c.Name)
+	}
+	want := []string{"GameContextActorInformations", "GameRolePlayActorInformations", "GameRolePlayNamedActorInformations"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Protocol.Hierarchy() = %v, want %v", got, want)
+	}
+	if chain[2] != c {
+		t.Errorf("expected the last class to be the argument")
+	}
+}
+
+func TestProtocol_Subclasses(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"GameContextActorInformations", []string{"GameRolePlayActorInformations", "GameRolePlayNamedActorInformations", "GameFightFighterInformations"}},
+		{"GameRolePlayNamedActorInformations", nil},
+		{"MissingMessage", []string{"OrphanMessage"}},
+		{"CycleAMessage", []string{"CycleBMessage"}},
+	}
+	p := inheritanceProtocol()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range p.Subclasses(tt.name) {
+				got = append(got, c.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Protocol.Subclasses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package d2protocolparser
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kelvyne/as3"
+	"github.com/kelvyne/as3/bytecode"
+)
+
+// PatternHandler analyzes the instructions of a serialize method matching a
+// Pattern. instrs starts at the first matched instruction and last is the
+// field returned by the previous handler. It returns the field it updated,
+// or nil when the instructions are not about a field of the class.
+type PatternHandler func(abc *as3.AbcFile, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error)
+
+// Pattern is an instruction sequence of a serialize method and the handler
+// extracting field informations from it
+type Pattern struct {
+	Name string
+	// Instrs contains the prefixes of the instruction names to match, in order
+	Instrs []string
+	// Priority orders the patterns, higher priorities are tried first. The
+	// built-in patterns use priorities from 0 to 700, longer patterns must
+	// usually be tried before shorter ones.
+	Priority int
+	Handler  PatternHandler
+}
+
+func (p Pattern) match(instrs []bytecode.Instr) bool {
+	if len(p.Instrs) > len(instrs) {
+		return false
+	}
+	for i, str := range p.Instrs {
+		if !strings.HasPrefix(instrs[i].Model.Name, str) {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	// bbwPattern is the name of the pattern setting a BooleanByteWrapper flag
+	bbwPattern = "BooleanByteWrapper"
+	// propertyPattern is the name of the pattern matching any getproperty
+	// of a field
+	propertyPattern = "property"
+)
+
+// registered is a Pattern and whether it is built-in
+type registered struct {
+	Pattern
+	builtin bool
+}
+
+var (
+	patternsMu sync.RWMutex
+	patterns   []registered
+)
+
+func init() {
+	builtins := []Pattern{
+		{"vector static length", []string{"getlocal", "increment", "convert", "setlocal", "getlocal", "pushbyte", "iflt"}, 700, handleVecPropDynamicLen},
+		{"vector TypeManager", []string{"getproperty", "getlocal", "getproperty", "getlex", "astypelate", "callproperty"}, 600, handleVecTypeManagerProp},
+		{bbwPattern, []string{"getlex", "getlocal", "pushbyte", "getlocal", "getproperty", "callproperty"}, 500, handleBBWProp},
+		{"vector scalar", []string{"getproperty", "getlocal", "getproperty", "callpropvoid"}, 400, handleVecScalarProp},
+		{"vector length", []string{"getproperty", "getproperty", "callpropvoid"}, 300, handleVecPropLength},
+		{"scalar", []string{"getproperty", "callpropvoid"}, 200, handleSimpleProp},
+		{"TypeManager", []string{"getproperty", "callproperty", "callpropvoid"}, 100, handleTypeManagerProp},
+		{propertyPattern, []string{"getproperty"}, 0, handleGetProperty},
+	}
+	for _, p := range builtins {
+		register(p, true)
+	}
+}
+
+// RegisterPattern adds a pattern used by every following extraction. Patterns
+// with the same priority are tried in registration order, after the built-in
+// ones. It panics if the pattern has no instruction or no handler.
+func RegisterPattern(p Pattern) {
+	if len(p.Instrs) == 0 {
+		panic("d2protocolparser: RegisterPattern with no instruction")
+	}
+	if p.Handler == nil {
+		panic("d2protocolparser: RegisterPattern with nil handler")
+	}
+	register(p, false)
+}
+
+func register(p Pattern, builtin bool) {
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+	// patterns is copied so that slices returned by registeredPatterns are
+	// never modified
+	ps := make([]registered, len(patterns), len(patterns)+1)
+	copy(ps, patterns)
+	ps = append(ps, registered{p, builtin})
+	sort.SliceStable(ps, func(i, j int) bool {
+		return ps[i].Priority > ps[j].Priority
+	})
+	patterns = ps
+}
+
+// Patterns returns the registered patterns in the order they are tried
+func Patterns() []Pattern {
+	var ps []Pattern
+	for _, p := range registeredPatterns() {
+		ps = append(ps, p.Pattern)
+	}
+	return ps
+}
+
+func registeredPatterns() []registered {
+	patternsMu.RLock()
+	defer patternsMu.RUnlock()
+	return patterns
+}
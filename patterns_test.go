@@ -0,0 +1,179 @@
+package d2protocolparser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kelvyne/as3"
+	"github.com/kelvyne/as3/bytecode"
+)
+
+func instrs(names ...string) []bytecode.Instr {
+	var is []bytecode.Instr
+	for _, n := range names {
+		is = append(is, bytecode.Instr{Model: bytecode.InstrModel{Name: n}})
+	}
+	return is
+}
+
+func TestPattern_match(t *testing.T) {
+	p := Pattern{Instrs: []string{"getproperty", "callprop"}}
+	tests := []struct {
+		name   string
+		instrs []bytecode.Instr
+		want   bool
+	}{
+		{"exact", instrs("getproperty", "callprop"), true},
+		{"prefix", instrs("getproperty", "callpropvoid", "returnvoid"), true},
+		{"too short", instrs("getproperty"), false},
+		{"mismatch", instrs("getlocal", "callpropvoid"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.match(tt.instrs); got != tt.want {
+				t.Errorf("Pattern.match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterPattern(t *testing.T) {
+	saved := registeredPatterns()
+	defer func() {
+		patternsMu.Lock()
+		patterns = saved
+		patternsMu.Unlock()
+	}()
+
+	handler := func(abc *as3.AbcFile, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
+		return nil, nil
+	}
+	RegisterPattern(Pattern{"first", []string{"getproperty"}, 1000, handler})
+	RegisterPattern(Pattern{"middle", []string{"getproperty"}, 250, handler})
+	RegisterPattern(Pattern{"last", []string{"getproperty"}, 0, handler})
+
+	var got []string
+	for _, p := range Patterns() {
+		got = append(got, p.Name)
+	}
+	want := []string{
+		"first", "vector static length", "vector TypeManager", "BooleanByteWrapper",
+		"vector scalar", "vector length", "middle", "scalar", "TypeManager", "property", "last",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Patterns() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Patterns() = %v, want %v", got, want)
+		}
+	}
+
+	if len(saved) != len(want)-3 {
+		t.Errorf("expected previously returned patterns to be left untouched")
+	}
+}
+
+func TestRegisterPattern_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Pattern
+	}{
+		{"no instruction", Pattern{Name: "empty", Handler: handleGetProperty}},
+		{"no handler", Pattern{Name: "nil", Instrs: []string{"getproperty"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected RegisterPattern to panic")
+				}
+			}()
+			RegisterPattern(tt.p)
+		})
+	}
+}
+
+// restorePatterns returns a function restoring the registered patterns
+func restorePatterns() func() {
+	saved := registeredPatterns()
+	return func() {
+		patternsMu.Lock()
+		patterns = saved
+		patternsMu.Unlock()
+	}
+}
+
+func Test_builder_extractSerializeMethods(t *testing.T) {
+	tests := []struct {
+		name     string
+		builtin  bool
+		consumed []bool
+		order    int
+	}{
+		// a registered pattern leaves the instructions to the next ones
+		{"registered", false, []bool{true, true, true, false}, 0},
+		// a built-in pattern skips them like the baseline extraction
+		{"built-in", true, []bool{false, false, false, false}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer restorePatterns()()
+			patternsMu.Lock()
+			patterns = nil
+			patternsMu.Unlock()
+
+			declined := 0
+			register(Pattern{"declining", []string{"getlocal", "pushbyte"}, 10, func(abc *as3.AbcFile, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
+				declined++
+				return nil, nil
+			}}, tt.builtin)
+			RegisterPattern(Pattern{"write", []string{"getlocal", "pushbyte", "callpropvoid"}, 5, func(abc *as3.AbcFile, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
+				return fields["level"], nil
+			}})
+
+			fields := map[string]*Field{"level": {Name: "level", Order: -1}}
+			b := &builder{}
+			consumed, _, err := b.extractSerializeMethods(nil, as3.Class{}, instrs("getlocal1", "pushbyte", "callpropvoid", "returnvoid"), fields)
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if declined != 1 {
+				t.Errorf("declining handler called %v times, want 1", declined)
+			}
+			if !reflect.DeepEqual(consumed, tt.consumed) {
+				t.Errorf("consumed = %v, want %v", consumed, tt.consumed)
+			}
+			if fields["level"].Order != tt.order {
+				t.Errorf("Order = %v, want %v", fields["level"].Order, tt.order)
+			}
+		})
+	}
+}
+
+func TestRegisterPattern_ExtractClass(t *testing.T) {
+	abc := open(t)
+	class, _ := abc.GetClassByName("GameFightOptionStateUpdateMessage")
+	b := &builder{}
+	want, err := b.ExtractClass(abc, class)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	defer restorePatterns()()
+	calls := 0
+	RegisterPattern(Pattern{"observer", []string{"getproperty", "callpropvoid"}, 1000, func(abc *as3.AbcFile, class as3.Class, fields map[string]*Field, instrs []bytecode.Instr, last *Field) (*Field, error) {
+		calls++
+		return nil, nil
+	}})
+	got, err := b.ExtractClass(abc, class)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if calls != len(want.Fields) {
+		t.Errorf("handler called %v times, want %v", calls, len(want.Fields))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("builder.ExtractClass() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,226 @@
+package d2protocolparser
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/kelvyne/as3"
+	"github.com/kelvyne/as3/bytecode"
+)
+
+// ErrExtractConstantValue means that the value of a constant is neither a
+// literal nor set from a literal in the static initializer
+var ErrExtractConstantValue = errors.New("constant value is not a literal")
+
+// Value is the value of an as3 constant. Type is "int32", "uint32",
+// "float64", "string", "bool" or "null" and tells which field holds it.
+type Value struct {
+	Type   string
+	Int    int64 // Int holds int32 and uint32 values
+	Number float64
+	Text   string
+	Bool   bool
+}
+
+func (v Value) String() string {
+	switch v.Type {
+	case "int32", "uint32":
+		return strconv.FormatInt(v.Int, 10)
+	case "float64":
+		return strconv.FormatFloat(v.Number, 'g', -1, 64)
+	case "string":
+		return strconv.Quote(v.Text)
+	case "bool":
+		return strconv.FormatBool(v.Bool)
+	}
+	return v.Type
+}
+
+// Constant is a static constant of a protocol class
+type Constant struct {
+	Class string
+	Name  string
+	Value Value
+}
+
+// Constants contains the protocol constants of the client, such as the
+// protocol build checked by the server during the handshake
+type Constants struct {
+	// Values lists the static constants of ProtocolConstantsEnum and Metadata
+	// in declaration order
+	Values []Constant
+}
+
+// Get returns the value of the constant name of class
+func (c Constants) Get(class, name string) (Value, bool) {
+	for _, v := range c.Values {
+		if v.Class == class && v.Name == name {
+			return v.Value, true
+		}
+	}
+	return Value{}, false
+}
+
+func (c Constants) byName(name string) (Value, bool) {
+	for _, v := range c.Values {
+		if v.Name == name {
+			return v.Value, true
+		}
+	}
+	return Value{}, false
+}
+
+// CurrentVersion returns PROTOCOL_BUILD, the protocol version of the client
+// sent in the ProtocolRequired message
+func (c Constants) CurrentVersion() (Value, bool) {
+	return c.byName("PROTOCOL_BUILD")
+}
+
+// RequiredVersion returns PROTOCOL_REQUIRED_BUILD, the oldest protocol
+// version the client accepts
+func (c Constants) RequiredVersion() (Value, bool) {
+	return c.byName("PROTOCOL_REQUIRED_BUILD")
+}
+
+const constantsNamespace = "com.ankamagames.dofus.network"
+
+// constantClasses lists the classes whose constants are extracted
+var constantClasses = map[string]bool{
+	"ProtocolConstantsEnum": true,
+	"Metadata":              true,
+}
+
+// extractConstants extracts the constants of the first definition of each
+// class of constantClasses. Missing classes are ignored, classes with a
+// constant that cannot be extracted are left out when keepGoing is set.
+func (b *builder) extractConstants() (Constants, error) {
+	var constants Constants
+	seen := map[string]bool{}
+	for _, ac := range b.classes() {
+		class := ac.class
+		if !constantClasses[class.Name] || seen[class.Name] || !strings.HasPrefix(class.Namespace, constantsNamespace) {
+			continue
+		}
+		seen[class.Name] = true
+		values, err := b.ExtractConstants(ac.abc, class)
+		if err != nil {
+			if !b.keepGoing {
+				return Constants{}, err
+			}
+			b.diagnostics = append(b.diagnostics, newDiagnostic(SeverityError, class.Name, class.Namespace, err))
+			continue
+		}
+		constants.Values = append(constants.Values, values...)
+	}
+	return constants, nil
+}
+
+// ExtractConstants extracts the static constants of a class. Constants
+// without a literal value are read from the static initializer.
+func (b *builder) ExtractConstants(abc *as3.AbcFile, class as3.Class) ([]Constant, error) {
+	var initialized map[string]Value
+	var constants []Constant
+	for _, slot := range class.ClassTraits.Slots {
+		if slot.Source.Kind != bytecode.TraitsInfoConst {
+			continue
+		}
+		v, err := b.staticValue(abc, class, slot, &initialized, ErrExtractConstantValue)
+		if err != nil {
+			return nil, err
+		}
+		constants = append(constants, Constant{class.Name, slot.Name, v})
+	}
+	return constants, nil
+}
+
+// staticValue returns the value of a static slot of class, read from the
+// static initializer when the slot has no literal value. initialized caches
+// the static initializer values between calls, errNotLiteral is returned
+// when neither holds the value.
+func (b *builder) staticValue(abc *as3.AbcFile, class as3.Class, slot as3.Slot, initialized *map[string]Value, errNotLiteral error) (Value, error) {
+	if v, ok := b.slotValue(abc, slot); ok {
+		return v, nil
+	}
+	if *initialized == nil {
+		values, err := b.initializedValues(abc, class)
+		if err != nil {
+			return Value{}, err
+		}
+		*initialized = values
+	}
+	v, ok := (*initialized)[slot.Name]
+	if !ok {
+		return Value{}, newExtractError(class.Name, class.Namespace, slot.Name, errNotLiteral)
+	}
+	return v, nil
+}
+
+func (b *builder) slotValue(abc *as3.AbcFile, slot as3.Slot) (Value, bool) {
+	pool := abc.Source.ConstantPool
+	i := slot.Source.VIndex
+	switch slot.Source.VKind {
+	case bytecode.SlotKindInt:
+		return Value{Type: "int32", Int: int64(pool.Integers[i])}, true
+	case bytecode.SlotKindUInt:
+		return Value{Type: "uint32", Int: int64(pool.UIntegers[i])}, true
+	case bytecode.SlotKindDouble:
+		return Value{Type: "float64", Number: pool.Doubles[i]}, true
+	case bytecode.SlotKindUtf8:
+		return Value{Type: "string", Text: pool.Strings[i]}, true
+	case bytecode.SlotKindTrue:
+		return Value{Type: "bool", Bool: true}, true
+	case bytecode.SlotKindFalse:
+		return Value{Type: "bool"}, true
+	case bytecode.SlotKindNull:
+		return Value{Type: "null"}, true
+	}
+	return Value{}, false
+}
+
+// pushedConstant returns the value pushed on the stack by a push instruction
+func (b *builder) pushedConstant(abc *as3.AbcFile, instr bytecode.Instr) (Value, bool) {
+	pool := abc.Source.ConstantPool
+	switch instr.Model.Name {
+	case "pushtrue":
+		return Value{Type: "bool", Bool: true}, true
+	case "pushfalse":
+		return Value{Type: "bool"}, true
+	case "pushnull":
+		return Value{Type: "null"}, true
+	case "pushstring":
+		return Value{Type: "string", Text: pool.Strings[instr.Operands[0]]}, true
+	case "pushuint":
+		return Value{Type: "uint32", Int: int64(pool.UIntegers[instr.Operands[0]])}, true
+	case "pushdouble":
+		return Value{Type: "float64", Number: pool.Doubles[instr.Operands[0]]}, true
+	}
+	if n, ok := b.pushedValue(abc, instr); ok {
+		return Value{Type: "int32", Int: int64(n)}, true
+	}
+	return Value{}, false
+}
+
+// initializedValues returns the values set from a literal by the static
+// initializer of a class, such as pushstring "1.0", initproperty PROTOCOL_BUILD
+func (b *builder) initializedValues(abc *as3.AbcFile, class as3.Class) (map[string]Value, error) {
+	m := abc.Methods[class.ClassInfo.CInit]
+	if err := m.BodyInfo.Disassemble(); err != nil {
+		return nil, newExtractError(class.Name, class.Namespace, "", err)
+	}
+	instrs := m.BodyInfo.Instructions
+	values := map[string]Value{}
+	for i := 1; i < len(instrs); i++ {
+		name := instrs[i].Model.Name
+		if name != "initproperty" && name != "setproperty" {
+			continue
+		}
+		v, ok := b.pushedConstant(abc, instrs[i-1])
+		if !ok {
+			continue
+		}
+		multiname := abc.Source.ConstantPool.Multinames[instrs[i].Operands[0]]
+		values[abc.Source.ConstantPool.Strings[multiname.Name]] = v
+	}
+	return values, nil
+}
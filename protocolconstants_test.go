@@ -0,0 +1,69 @@
+package d2protocolparser
+
+import (
+	"testing"
+
+	"github.com/kelvyne/as3"
+)
+
+func TestValue_String(t *testing.T) {
+	tests := []struct {
+		v    Value
+		want string
+	}{
+		{Value{Type: "int32", Int: -3}, "-3"},
+		{Value{Type: "uint32", Int: 1658}, "1658"},
+		{Value{Type: "float64", Number: 0.5}, "0.5"},
+		{Value{Type: "string", Text: "1.0.3"}, `"1.0.3"`},
+		{Value{Type: "bool", Bool: true}, "true"},
+		{Value{Type: "null"}, "null"},
+	}
+	for _, tt := range tests {
+		if got := tt.v.String(); got != tt.want {
+			t.Errorf("Value.String() = %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func TestConstants(t *testing.T) {
+	c := Constants{Values: []Constant{
+		{"ProtocolConstantsEnum", "PROTOCOL_BUILD", Value{Type: "int32", Int: 1658}},
+		{"ProtocolConstantsEnum", "PROTOCOL_REQUIRED_BUILD", Value{Type: "int32", Int: 1657}},
+		{"Metadata", "PROTOCOL_BUILD", Value{Type: "string", Text: "1.0.3"}},
+	}}
+	if v, ok := c.Get("Metadata", "PROTOCOL_BUILD"); !ok || v.Text != "1.0.3" {
+		t.Errorf("Constants.Get() = %v, %v", v, ok)
+	}
+	if _, ok := c.Get("Metadata", "PROTOCOL_REQUIRED_BUILD"); ok {
+		t.Errorf("expected Metadata.PROTOCOL_REQUIRED_BUILD to be missing")
+	}
+	if v, ok := c.CurrentVersion(); !ok || v.Int != 1658 {
+		t.Errorf("Constants.CurrentVersion() = %v, %v", v, ok)
+	}
+	if v, ok := c.RequiredVersion(); !ok || v.Int != 1657 {
+		t.Errorf("Constants.RequiredVersion() = %v, %v", v, ok)
+	}
+	if _, ok := (Constants{}).CurrentVersion(); ok {
+		t.Errorf("expected no version in empty Constants")
+	}
+}
+
+func Test_builder_extractConstants(t *testing.T) {
+	abc := open(t)
+	b := &builder{abcFiles: []*as3.AbcFile{abc}}
+	c, err := b.extractConstants()
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	current, ok := c.CurrentVersion()
+	if !ok {
+		t.Fatalf("PROTOCOL_BUILD not found in %v", c.Values)
+	}
+	required, ok := c.RequiredVersion()
+	if !ok {
+		t.Fatalf("PROTOCOL_REQUIRED_BUILD not found in %v", c.Values)
+	}
+	if current.Type != required.Type {
+		t.Errorf("expected versions of the same type, got %v and %v", current, required)
+	}
+}
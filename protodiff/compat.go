@@ -0,0 +1,274 @@
+package protodiff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kelvyne/d2protocolparser"
+)
+
+// ErrUnknownClass means that a class is missing from one of the protocols
+var ErrUnknownClass = errors.New("unknown class")
+
+// Compatibility tells whether payloads of a class can still be read with its
+// old definition
+type Compatibility string
+
+// Compatibility levels
+const (
+	// WireCompatible classes have the same protocol id and wire layout
+	WireCompatible Compatibility = "wire-compatible"
+	// IDOnlyChange classes have the same wire layout but a new protocol id
+	IDOnlyChange Compatibility = "id-only-change"
+	// WireBreaking classes have a different wire layout
+	WireBreaking Compatibility = "wire-breaking"
+)
+
+// ClassCompatibility is the Compatibility of a class existing in both protocols
+type ClassCompatibility struct {
+	Category      Category      `json:"category"`
+	Name          string        `json:"name"`
+	Compatibility Compatibility `json:"compatibility"`
+	Reason        string        `json:"reason,omitempty"`
+}
+
+func (c ClassCompatibility) String() string {
+	if c.Reason == "" {
+		return fmt.Sprintf("%v %v: %v", c.Category, c.Name, c.Compatibility)
+	}
+	return fmt.Sprintf("%v %v: %v (%v)", c.Category, c.Name, c.Compatibility, c.Reason)
+}
+
+// layout flattens the wire layout of classes: inherited fields come first,
+// BooleanByteWrapper boxes are written in place of their flags and class
+// fields are expanded. Names are not written on the wire so tokens only keep
+// them to explain a difference. Polymorphic fields are compared apart
+// since the type they hold is only known at runtime. Classes missing from the
+// protocol are kept as a single token.
+type layout struct {
+	p        *d2protocolparser.Protocol
+	classes  map[string]*d2protocolparser.Class
+	visiting map[string]bool
+}
+
+// token is a single value written on the wire
+type token struct {
+	path  string // path is the name of the field writing the value
+	wire  string
+	field *d2protocolparser.Field // field is set for polymorphic fields
+}
+
+func (t token) String() string {
+	if t.path == "" {
+		return t.wire
+	}
+	return t.path + ": " + t.wire
+}
+
+func newLayout(p *d2protocolparser.Protocol) *layout {
+	l := &layout{p: p, classes: map[string]*d2protocolparser.Class{}, visiting: map[string]bool{}}
+	for i := range p.Messages {
+		l.classes[p.Messages[i].Name] = &p.Messages[i]
+	}
+	for i := range p.Types {
+		l.classes[p.Types[i].Name] = &p.Types[i]
+	}
+	return l
+}
+
+func (l *layout) tokens(name, prefix string) []token {
+	path := strings.TrimSuffix(prefix, ".")
+	c, ok := l.classes[name]
+	if !ok {
+		return []token{{path: path, wire: "unknown " + name}}
+	}
+	if l.visiting[name] {
+		return []token{{path: path, wire: "recursive " + name}}
+	}
+	l.visiting[name] = true
+	defer delete(l.visiting, name)
+
+	var tokens []token
+	if c.Parent != "" {
+		tokens = append(tokens, l.tokens(c.Parent, prefix)...)
+	}
+
+	groups := c.BBWGroups()
+	box := 0
+	for i := range c.Fields {
+		f := &c.Fields[i]
+		if f.UseBBW {
+			if box < len(groups) && groups[box].Index == i {
+				for _, m := range groups[box].Members {
+					tokens = append(tokens, token{path: prefix + m.Field, wire: fmt.Sprintf("box %v bit %v", box, m.Bit)})
+				}
+				box++
+			}
+			continue
+		}
+		path := prefix + f.Name
+		if f.IsVector {
+			tokens = append(tokens, token{path: path, wire: "vector " + vectorString(*f)})
+		}
+		switch {
+		case f.Method != "":
+			tokens = append(tokens, token{path: path, wire: f.WriteMethod + " " + f.Method})
+		case f.UseTypeManager:
+			tokens = append(tokens, token{path: path, wire: "polymorphic", field: f})
+		default:
+			tokens = append(tokens, l.tokens(f.Type, path+".")...)
+		}
+	}
+	return tokens
+}
+
+func compareLayouts(from, to []token) string {
+	for i := 0; i < len(from) && i < len(to); i++ {
+		if from[i].wire != to[i].wire {
+			return fmt.Sprintf("%q became %q", from[i], to[i])
+		}
+	}
+	switch {
+	case len(from) > len(to):
+		return fmt.Sprintf("%q removed", from[len(to)])
+	case len(to) > len(from):
+		return fmt.Sprintf("%q added", to[len(from)])
+	}
+	return ""
+}
+
+// comparison compares the classes of two protocols
+type comparison struct {
+	from, to *layout
+	checking map[string]bool
+}
+
+func newComparison(from, to *d2protocolparser.Protocol) *comparison {
+	return &comparison{newLayout(from), newLayout(to), map[string]bool{}}
+}
+
+// breaking returns why payloads of the class name written with its
+// definition in to cannot be read with its definition in from, or an empty
+// string when they can
+func (c *comparison) breaking(name string) string {
+	// a class holding itself through a polymorphic field is compared once
+	if c.checking[name] {
+		return ""
+	}
+	c.checking[name] = true
+	defer delete(c.checking, name)
+
+	fromTokens, toTokens := c.from.tokens(name, ""), c.to.tokens(name, "")
+	if reason := compareLayouts(fromTokens, toTokens); reason != "" {
+		return reason
+	}
+	for i, t := range toTokens {
+		if t.field == nil {
+			continue
+		}
+		if reason := c.polymorphic(fromTokens[i], t); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// polymorphic compares a polymorphic field: every type it can hold in to must
+// be allowed in from, with the same type id and a compatible layout
+func (c *comparison) polymorphic(from, to token) string {
+	allowed, err := c.to.p.AllowedTypes(*to.field)
+	if err != nil {
+		return ""
+	}
+	old := map[string]*d2protocolparser.Class{}
+	if oldAllowed, err := c.from.p.AllowedTypes(*from.field); err == nil {
+		for _, t := range oldAllowed {
+			old[t.Name] = t
+		}
+	}
+	for _, t := range allowed {
+		o, ok := old[t.Name]
+		switch {
+		case !ok:
+			return fmt.Sprintf("%v (%v): type not allowed by the old protocol", to.path, t.Name)
+		case o.ProtocolID != t.ProtocolID:
+			return fmt.Sprintf("%v (%v): type id %v became %v", to.path, t.Name, o.ProtocolID, t.ProtocolID)
+		}
+		if reason := c.breaking(t.Name); reason != "" {
+			return fmt.Sprintf("%v (%v): %v", to.path, t.Name, reason)
+		}
+	}
+	return ""
+}
+
+func (c *comparison) classify(cat Category, from, to *d2protocolparser.Class) ClassCompatibility {
+	cc := ClassCompatibility{Category: cat, Name: to.Name, Compatibility: WireCompatible}
+	if reason := c.breaking(to.Name); reason != "" {
+		cc.Compatibility = WireBreaking
+		cc.Reason = reason
+	} else if from.ProtocolID != to.ProtocolID {
+		cc.Compatibility = IDOnlyChange
+		cc.Reason = fmt.Sprintf("protocol id %v became %v", from.ProtocolID, to.ProtocolID)
+	}
+	return cc
+}
+
+func category(p *d2protocolparser.Protocol, name string) (*d2protocolparser.Class, Category) {
+	for i := range p.Messages {
+		if p.Messages[i].Name == name {
+			return &p.Messages[i], Message
+		}
+	}
+	for i := range p.Types {
+		if p.Types[i].Name == name {
+			return &p.Types[i], Type
+		}
+	}
+	return nil, ""
+}
+
+// Classify returns the Compatibility of every class that exists in both
+// protocols and whose protocol id or wire layout changed, including through
+// a field or a parent
+func Classify(from, to *d2protocolparser.Protocol) []ClassCompatibility {
+	c := newComparison(from, to)
+	var out []ClassCompatibility
+	for _, cat := range []struct {
+		c       Category
+		classes []d2protocolparser.Class
+	}{{Message, to.Messages}, {Type, to.Types}} {
+		for i := range cat.classes {
+			class := &cat.classes[i]
+			old, ok := c.from.classes[class.Name]
+			if !ok {
+				continue
+			}
+			if cc := c.classify(cat.c, old, class); cc.Compatibility != WireCompatible {
+				out = append(out, cc)
+			}
+		}
+	}
+	return out
+}
+
+// Check returns the Compatibility of the named message or type
+func Check(from, to *d2protocolparser.Protocol, name string) (ClassCompatibility, error) {
+	old, _ := category(from, name)
+	c, cat := category(to, name)
+	if old == nil || c == nil {
+		return ClassCompatibility{}, fmt.Errorf("%v: %w", name, ErrUnknownClass)
+	}
+	return newComparison(from, to).classify(cat, old, c), nil
+}
+
+// Decodable tells whether payloads of the named message in the protocol to
+// can be decoded with its definition in the protocol from. A new protocol id
+// does not prevent decoding, but the caller must map it to the old one.
+func Decodable(from, to *d2protocolparser.Protocol, name string) (bool, error) {
+	c, err := Check(from, to, name)
+	if err != nil {
+		return false, err
+	}
+	return c.Compatibility != WireBreaking, nil
+}
@@ -0,0 +1,130 @@
+package protodiff
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kelvyne/d2protocolparser"
+)
+
+func compatProtocols() (*d2protocolparser.Protocol, *d2protocolparser.Protocol) {
+	from := &d2protocolparser.Protocol{
+		Messages: []d2protocolparser.Class{
+			{Name: "Same", ProtocolID: 1, Fields: []d2protocolparser.Field{
+				{Name: "a", Type: "int16", WriteMethod: "writeShort", Method: "Int16"},
+			}},
+			{Name: "NewID", ProtocolID: 2},
+			{Name: "Nested", ProtocolID: 3, Fields: []d2protocolparser.Field{
+				{Name: "look", Type: "Look"},
+			}},
+			{Name: "Child", Parent: "Base", ProtocolID: 4},
+			{Name: "Polymorphic", ProtocolID: 5, Fields: []d2protocolparser.Field{
+				{Name: "look", Type: "Look", UseTypeManager: true},
+			}},
+			{Name: "Flags", ProtocolID: 6, Fields: []d2protocolparser.Field{
+				{Name: "dead", Type: "bool", UseBBW: true, BBWPosition: 0},
+				{Name: "level", Type: "int8", WriteMethod: "writeByte", Method: "Int8"},
+			}},
+			{Name: "Renamed", ProtocolID: 7, Fields: []d2protocolparser.Field{
+				{Name: "a", Type: "int16", WriteMethod: "writeShort", Method: "Int16"},
+			}},
+			{Name: "Actors", ProtocolID: 8, Fields: []d2protocolparser.Field{
+				{Name: "actor", Type: "Actor", UseTypeManager: true},
+			}},
+		},
+		Types: []d2protocolparser.Class{
+			{Name: "Look", ProtocolID: 10, Fields: []d2protocolparser.Field{
+				{Name: "bones", Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16"},
+			}},
+			{Name: "Base", ProtocolID: 11, Fields: []d2protocolparser.Field{
+				{Name: "ids", Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeShort"},
+			}},
+
+			{Name: "Actor", ProtocolID: 12},
+		},
+	}
+	to := &d2protocolparser.Protocol{
+		Messages: []d2protocolparser.Class{
+			{Name: "Same", ProtocolID: 1, Fields: []d2protocolparser.Field{
+				{Name: "a", Type: "int16", WriteMethod: "writeShort", Method: "Int16"},
+			}},
+			{Name: "NewID", ProtocolID: 20},
+			{Name: "Nested", ProtocolID: 3, Fields: []d2protocolparser.Field{
+				{Name: "look", Type: "Look"},
+			}},
+			{Name: "Child", Parent: "Base", ProtocolID: 4},
+			{Name: "Polymorphic", ProtocolID: 5, Fields: []d2protocolparser.Field{
+				{Name: "look", Type: "Look", UseTypeManager: true},
+			}},
+			{Name: "Flags", ProtocolID: 6, Fields: []d2protocolparser.Field{
+				{Name: "level", Type: "int8", WriteMethod: "writeByte", Method: "Int8"},
+				{Name: "dead", Type: "bool", UseBBW: true, BBWPosition: 0},
+			}},
+			{Name: "Renamed", ProtocolID: 7, Fields: []d2protocolparser.Field{
+				{Name: "b", Type: "int16", WriteMethod: "writeShort", Method: "Int16"},
+			}},
+			{Name: "Actors", ProtocolID: 8, Fields: []d2protocolparser.Field{
+				{Name: "actor", Type: "Actor", UseTypeManager: true},
+			}},
+		},
+		Types: []d2protocolparser.Class{
+			{Name: "Look", ProtocolID: 10, Fields: []d2protocolparser.Field{
+				{Name: "bones", Type: "uint16", WriteMethod: "writeShort", Method: "UInt16"},
+			}},
+			{Name: "Base", ProtocolID: 11, Fields: []d2protocolparser.Field{
+				{Name: "ids", Type: "uint16", WriteMethod: "writeVarShort", Method: "VarUInt16", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeVarInt"},
+			}},
+
+			{Name: "Actor", ProtocolID: 12},
+			{Name: "Monster", Parent: "Actor", ProtocolID: 13},
+		},
+	}
+	return from, to
+}
+
+func TestClassify(t *testing.T) {
+	from, to := compatProtocols()
+	want := []ClassCompatibility{
+		{Message, "NewID", IDOnlyChange, "protocol id 2 became 20"},
+		{Message, "Nested", WireBreaking, `"look.bones: writeVarShort VarUInt16" became "look.bones: writeShort UInt16"`},
+		{Message, "Child", WireBreaking, `"ids: vector dynamic writeShort" became "ids: vector dynamic writeVarInt"`},
+		{Message, "Polymorphic", WireBreaking, `look (Look): "bones: writeVarShort VarUInt16" became "bones: writeShort UInt16"`},
+		{Message, "Flags", WireBreaking, `"dead: box 0 bit 0" became "level: writeByte Int8"`},
+		{Message, "Actors", WireBreaking, "actor (Monster): type not allowed by the old protocol"},
+		{Type, "Look", WireBreaking, `"bones: writeVarShort VarUInt16" became "bones: writeShort UInt16"`},
+		{Type, "Base", WireBreaking, `"ids: vector dynamic writeShort" became "ids: vector dynamic writeVarInt"`},
+	}
+	if got := Classify(from, to); !reflect.DeepEqual(got, want) {
+		t.Errorf("Classify() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestDecodable(t *testing.T) {
+	from, to := compatProtocols()
+	tests := []struct {
+		name    string
+		want    bool
+		wantErr error
+	}{
+		{"Same", true, nil},
+		{"NewID", true, nil},
+		{"Nested", false, nil},
+		{"Polymorphic", false, nil},
+		{"Renamed", true, nil},
+		{"Actors", false, nil},
+		{"Missing", false, ErrUnknownClass},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decodable(from, to, tt.name)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decodable() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Decodable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+// Package protodiff compares two versions of a Dofus 2 Protocol
+package protodiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kelvyne/d2protocolparser"
+)
+
+// Kind is the kind of a Change
+type Kind string
+
+// Change kinds
+const (
+	Added             Kind = "added"
+	Removed           Kind = "removed"
+	Renamed           Kind = "renamed"
+	ProtocolIDChanged Kind = "protocol-id-changed"
+	ParentChanged     Kind = "parent-changed"
+
+	FieldAdded         Kind = "field-added"
+	FieldRemoved       Kind = "field-removed"
+	FieldMoved         Kind = "field-moved"
+	FieldTypeChanged   Kind = "field-type-changed"
+	FieldMethodChanged Kind = "field-method-changed"
+	FieldVectorChanged Kind = "field-vector-changed"
+	FieldTypeManager   Kind = "field-type-manager-changed"
+	FieldBBWChanged    Kind = "field-bbw-changed"
+
+	EnumValueAdded   Kind = "enum-value-added"
+	EnumValueRemoved Kind = "enum-value-removed"
+	EnumValueChanged Kind = "enum-value-changed"
+)
+
+// Category tells whether a change concerns a message, a type or an enum
+type Category string
+
+// Change categories
+const (
+	Message Category = "message"
+	Type    Category = "type"
+	Enum    Category = "enum"
+)
+
+// Change is a single difference between two protocols.
+// Old and New hold the old and new values of what changed, when relevant.
+type Change struct {
+	Category Category `json:"category"`
+	Kind     Kind     `json:"kind"`
+	Name     string   `json:"name"`
+	Field    string   `json:"field,omitempty"`
+	Old      string   `json:"old,omitempty"`
+	New      string   `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	subject := fmt.Sprintf("%v %v", c.Category, c.Name)
+	if c.Field != "" {
+		subject += "." + c.Field
+	}
+	switch {
+	case c.Old != "" && c.New != "":
+		return fmt.Sprintf("%v: %v %v -> %v", subject, c.Kind, c.Old, c.New)
+	case c.New != "":
+		return fmt.Sprintf("%v: %v %v", subject, c.Kind, c.New)
+	case c.Old != "":
+		return fmt.Sprintf("%v: %v %v", subject, c.Kind, c.Old)
+	}
+	return fmt.Sprintf("%v: %v", subject, c.Kind)
+}
+
+// Report lists the changes between two protocols and the classes whose
+// wire compatibility changed.
+// It is meant to be marshaled as JSON for machine consumption.
+type Report struct {
+	OldVersion    d2protocolparser.Version `json:"oldVersion"`
+	NewVersion    d2protocolparser.Version `json:"newVersion"`
+	Changes       []Change                 `json:"changes"`
+	Compatibility []ClassCompatibility     `json:"compatibility"`
+}
+
+func versionString(v d2protocolparser.Version) string {
+	return fmt.Sprintf("%v.%v.%v.%v.%v", v.Major, v.Minor, v.Release, v.Revision, v.Patch)
+}
+
+// WriteText writes a human-readable rendering of r to w
+func (r *Report) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%v -> %v: %v changes\n", versionString(r.OldVersion), versionString(r.NewVersion), len(r.Changes)); err != nil {
+		return err
+	}
+	for _, c := range r.Changes {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return err
+		}
+	}
+	for _, c := range r.Compatibility {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Report) String() string {
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	return buf.String()
+}
+
+// Diff lists the changes needed to go from the protocol from to the protocol to
+func Diff(from, to *d2protocolparser.Protocol) *Report {
+	r := &Report{OldVersion: from.Version, NewVersion: to.Version, Changes: []Change{}}
+	r.diffClasses(Message, from.Messages, to.Messages)
+	r.diffClasses(Type, from.Types, to.Types)
+	r.diffEnums(from.Enums, to.Enums)
+	sort.Stable(byCategory(r.Changes))
+	r.Compatibility = Classify(from, to)
+	if r.Compatibility == nil {
+		r.Compatibility = []ClassCompatibility{}
+	}
+	return r
+}
+
+var categoryOrder = map[Category]int{Message: 0, Type: 1, Enum: 2}
+
+type byCategory []Change
+
+func (c byCategory) Len() int      { return len(c) }
+func (c byCategory) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byCategory) Less(i, j int) bool {
+	if c[i].Category != c[j].Category {
+		return categoryOrder[c[i].Category] < categoryOrder[c[j].Category]
+	}
+	return c[i].Name < c[j].Name
+}
+
+func (r *Report) add(c Change) {
+	r.Changes = append(r.Changes, c)
+}
+
+func indexClasses(classes []d2protocolparser.Class) (map[string]*d2protocolparser.Class, map[uint16]*d2protocolparser.Class) {
+	byName := map[string]*d2protocolparser.Class{}
+	byID := map[uint16]*d2protocolparser.Class{}
+	for i := range classes {
+		byName[classes[i].Name] = &classes[i]
+		byID[classes[i].ProtocolID] = &classes[i]
+	}
+	return byName, byID
+}
+
+func (r *Report) diffClasses(cat Category, from, to []d2protocolparser.Class) {
+	fromNames, fromIDs := indexClasses(from)
+	toNames, _ := indexClasses(to)
+
+	// a class is renamed when its name disappeared but its protocol id is
+	// now used by a class that did not exist before
+	renamed := map[string]bool{}
+	for i := range to {
+		c := &to[i]
+		if _, ok := fromNames[c.Name]; ok {
+			continue
+		}
+		old, ok := fromIDs[c.ProtocolID]
+		if ok {
+			_, stillExists := toNames[old.Name]
+			ok = !stillExists && !renamed[old.Name]
+		}
+		if !ok {
+			r.add(Change{Category: cat, Kind: Added, Name: c.Name})
+			continue
+		}
+		renamed[old.Name] = true
+		r.add(Change{Category: cat, Kind: Renamed, Name: c.Name, Old: old.Name, New: c.Name})
+		r.diffClass(cat, old, c)
+	}
+
+	for i := range from {
+		c := &from[i]
+		if renamed[c.Name] {
+			continue
+		}
+		n, ok := toNames[c.Name]
+		if !ok {
+			r.add(Change{Category: cat, Kind: Removed, Name: c.Name})
+			continue
+		}
+		if c.ProtocolID != n.ProtocolID {
+			r.add(Change{Category: cat, Kind: ProtocolIDChanged, Name: c.Name,
+				Old: fmt.Sprint(c.ProtocolID), New: fmt.Sprint(n.ProtocolID)})
+		}
+		r.diffClass(cat, c, n)
+	}
+}
+
+func vectorString(f d2protocolparser.Field) string {
+	switch {
+	case !f.IsVector:
+		return "scalar"
+	case f.IsDynamicLength:
+		return "dynamic " + f.WriteLengthMethod
+	}
+	return fmt.Sprintf("static %v", f.Length)
+}
+
+func bbwString(f d2protocolparser.Field) string {
+	if !f.UseBBW {
+		return "none"
+	}
+	return fmt.Sprint(f.BBWPosition)
+}
+
+func fieldString(f d2protocolparser.Field) string {
+	parts := []string{f.Type}
+	if f.WriteMethod != "" {
+		parts = append(parts, f.WriteMethod)
+	}
+	if f.IsVector {
+		parts = append(parts, "vector "+vectorString(f))
+	}
+	if f.UseTypeManager {
+		parts = append(parts, "type manager")
+	}
+	if f.UseBBW {
+		parts = append(parts, "bbw "+bbwString(f))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (r *Report) diffClass(cat Category, from, to *d2protocolparser.Class) {
+	name := to.Name
+	if from.Parent != to.Parent {
+		r.add(Change{Category: cat, Kind: ParentChanged, Name: name, Old: from.Parent, New: to.Parent})
+	}
+
+	fromFields := map[string]int{}
+	for i, f := range from.Fields {
+		fromFields[f.Name] = i
+	}
+	toFields := map[string]int{}
+	for i, f := range to.Fields {
+		toFields[f.Name] = i
+	}
+
+	var fromCommon, toCommon []string
+	for _, f := range from.Fields {
+		if _, ok := toFields[f.Name]; !ok {
+			r.add(Change{Category: cat, Kind: FieldRemoved, Name: name, Field: f.Name, Old: fieldString(f)})
+			continue
+		}
+		fromCommon = append(fromCommon, f.Name)
+	}
+	for _, f := range to.Fields {
+		i, ok := fromFields[f.Name]
+		if !ok {
+			r.add(Change{Category: cat, Kind: FieldAdded, Name: name, Field: f.Name, New: fieldString(f)})
+			continue
+		}
+		toCommon = append(toCommon, f.Name)
+		r.diffField(cat, name, from.Fields[i], f)
+	}
+
+	// fields are moved when their order relative to the other common fields changed
+	for i := range toCommon {
+		if fromCommon[i] == toCommon[i] {
+			continue
+		}
+		f := toCommon[i]
+		r.add(Change{Category: cat, Kind: FieldMoved, Name: name, Field: f,
+			Old: fmt.Sprint(fromFields[f]), New: fmt.Sprint(toFields[f])})
+	}
+}
+
+func (r *Report) diffField(cat Category, name string, from, to d2protocolparser.Field) {
+	change := func(kind Kind, a, b string) {
+		if a != b {
+			r.add(Change{Category: cat, Kind: kind, Name: name, Field: to.Name, Old: a, New: b})
+		}
+	}
+	change(FieldTypeChanged, from.Type, to.Type)
+	change(FieldMethodChanged, from.WriteMethod, to.WriteMethod)
+	change(FieldVectorChanged, vectorString(from), vectorString(to))
+	change(FieldTypeManager, fmt.Sprint(from.UseTypeManager), fmt.Sprint(to.UseTypeManager))
+	change(FieldBBWChanged, bbwString(from), bbwString(to))
+}
+
+func (r *Report) diffEnums(from, to []d2protocolparser.Enum) {
+	fromEnums := map[string]*d2protocolparser.Enum{}
+	for i := range from {
+		fromEnums[from[i].Name] = &from[i]
+	}
+	toEnums := map[string]bool{}
+	for _, e := range to {
+		toEnums[e.Name] = true
+		old, ok := fromEnums[e.Name]
+		if !ok {
+			r.add(Change{Category: Enum, Kind: Added, Name: e.Name})
+			continue
+		}
+		r.diffEnum(old, &e)
+	}
+	for _, e := range from {
+		if !toEnums[e.Name] {
+			r.add(Change{Category: Enum, Kind: Removed, Name: e.Name})
+		}
+	}
+}
+
+func (r *Report) diffEnum(from, to *d2protocolparser.Enum) {
+	fromValues := map[string]d2protocolparser.Value{}
+	for _, v := range from.Values {
+		fromValues[v.Name] = v.Value
+	}
+	toValues := map[string]bool{}
+	for _, v := range to.Values {
+		toValues[v.Name] = true
+		old, ok := fromValues[v.Name]
+		switch {
+		case !ok:
+			r.add(Change{Category: Enum, Kind: EnumValueAdded, Name: to.Name, Field: v.Name, New: fmt.Sprint(v.Value)})
+		case old != v.Value:
+			r.add(Change{Category: Enum, Kind: EnumValueChanged, Name: to.Name, Field: v.Name, Old: fmt.Sprint(old), New: fmt.Sprint(v.Value)})
+		}
+	}
+	for _, v := range from.Values {
+		if !toValues[v.Name] {
+			r.add(Change{Category: Enum, Kind: EnumValueRemoved, Name: to.Name, Field: v.Name, Old: fmt.Sprint(v.Value)})
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package protodiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kelvyne/d2protocolparser"
+)
+
+func TestDiff(t *testing.T) {
+	from := &d2protocolparser.Protocol{
+		Messages: []d2protocolparser.Class{
+			{Name: "Kept", ProtocolID: 1, Fields: []d2protocolparser.Field{
+				{Name: "a", Type: "int16", WriteMethod: "writeShort"},
+				{Name: "b", Type: "int16", WriteMethod: "writeShort"},
+				{Name: "c", Type: "string", WriteMethod: "writeUTF"},
+				{Name: "flag", Type: "bool", UseBBW: true, BBWPosition: 0},
+				{Name: "gone", Type: "int8", WriteMethod: "writeByte"},
+			}},
+			{Name: "OldName", ProtocolID: 2},
+			{Name: "Moved", ProtocolID: 3},
+			{Name: "Removed", ProtocolID: 4},
+		},
+		Enums: []d2protocolparser.Enum{
+			{Name: "E", Values: []d2protocolparser.EnumValue{{Name: "A", Value: d2protocolparser.Value{Type: "int32", Int: 0}}, {Name: "B", Value: d2protocolparser.Value{Type: "int32", Int: 1}}}},
+		},
+	}
+	to := &d2protocolparser.Protocol{
+		Messages: []d2protocolparser.Class{
+			{Name: "Kept", ProtocolID: 1, Fields: []d2protocolparser.Field{
+				{Name: "b", Type: "int16", WriteMethod: "writeShort"},
+				{Name: "a", Type: "int16", WriteMethod: "writeShort"},
+				{Name: "c", Type: "string", WriteMethod: "writeUTF", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeShort"},
+				{Name: "flag", Type: "bool", UseBBW: true, BBWPosition: 1},
+				{Name: "new", Type: "uint16", WriteMethod: "writeVarShort"},
+			}},
+			{Name: "NewName", ProtocolID: 2},
+			{Name: "Moved", ProtocolID: 30},
+			{Name: "Added", ProtocolID: 5},
+		},
+		Enums: []d2protocolparser.Enum{
+			{Name: "E", Values: []d2protocolparser.EnumValue{{Name: "A", Value: d2protocolparser.Value{Type: "int32", Int: 2}}}},
+			{Name: "F"},
+		},
+	}
+
+	want := []Change{
+		{Message, Added, "Added", "", "", ""},
+		{Message, FieldRemoved, "Kept", "gone", "int8, writeByte", ""},
+		{Message, FieldVectorChanged, "Kept", "c", "scalar", "dynamic writeShort"},
+		{Message, FieldBBWChanged, "Kept", "flag", "0", "1"},
+		{Message, FieldAdded, "Kept", "new", "", "uint16, writeVarShort"},
+		{Message, FieldMoved, "Kept", "b", "1", "0"},
+		{Message, FieldMoved, "Kept", "a", "0", "1"},
+		{Message, ProtocolIDChanged, "Moved", "", "3", "30"},
+		{Message, Renamed, "NewName", "", "OldName", "NewName"},
+		{Message, Removed, "Removed", "", "", ""},
+		{Enum, EnumValueChanged, "E", "A", "0", "2"},
+		{Enum, EnumValueRemoved, "E", "B", "1", ""},
+		{Enum, Added, "F", "", "", ""},
+	}
+	got := Diff(from, to)
+	if !reflect.DeepEqual(got.Changes, want) {
+		t.Errorf("Diff() =\n%v\nwant\n%v", got.Changes, want)
+	}
+
+	text := got.String()
+	if !strings.Contains(text, "message Kept.flag: field-bbw-changed 0 -> 1\n") {
+		t.Errorf("unexpected text rendering:\n%v", text)
+	}
+}
+
+func TestDiff_Identical(t *testing.T) {
+	p := &d2protocolparser.Protocol{Messages: []d2protocolparser.Class{{Name: "A", ProtocolID: 1}}}
+	if got := Diff(p, p); len(got.Changes) != 0 {
+		t.Errorf("Diff() = %v, want no changes", got.Changes)
+	}
+}
+
+func TestDiff_Fixtures(t *testing.T) {
+	from, err := d2protocolparser.Build("../fixtures/DofusInvoker.swf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := d2protocolparser.Build("../fixtures/DofusInvoker2.swf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Diff(from, to)
+	if len(r.Changes) == 0 {
+		t.Errorf("expected changes between 2.39 and 2.42")
+	}
+	if !reflect.DeepEqual(r.NewVersion, to.Version) {
+		t.Errorf("NewVersion = %v, want %v", r.NewVersion, to.Version)
+	}
+	if len(Diff(to, to).Changes) != 0 {
+		t.Errorf("expected no changes between identical protocols")
+	}
+}
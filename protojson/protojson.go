@@ -0,0 +1,337 @@
+// Package protojson reads and writes a Protocol as a versioned JSON document.
+//
+// The document layout is described by the JSON Schema published in
+// schema-v2.json, schema.json describes version 1. Every key is always present
+// so that consumers do not depend on the Go representation of the Protocol.
+// Published schemas reject unknown keys, so SchemaVersion changes and a new
+// schema is published whenever the layout changes. Documents of older versions
+// are still read.
+package protojson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/kelvyne/d2protocolparser"
+)
+
+// SchemaVersion is the version of the document layout written by this package.
+// Version 2 added the field order and range constraints, the class hash,
+// direction and BooleanByteWrapper bytes, the protocol constants and typed
+// enumeration values.
+const SchemaVersion = 2
+
+// ErrUnsupportedSchema means that a document uses an unknown schema version
+var ErrUnsupportedSchema = errors.New("unsupported schema version")
+
+type document struct {
+	SchemaVersion int        `json:"schemaVersion"`
+	Version       version    `json:"version"`
+	Constants     []constant `json:"constants"`
+	Messages      []class    `json:"messages"`
+	Types         []class    `json:"types"`
+	Enums         []enum     `json:"enums"`
+}
+
+type version struct {
+	Major    uint `json:"major"`
+	Minor    uint `json:"minor"`
+	Release  uint `json:"release"`
+	Revision uint `json:"revision"`
+	Patch    uint `json:"patch"`
+}
+
+// constant holds its value as a JSON number, string, boolean or null
+// according to its type
+type constant struct {
+	Class string      `json:"class"`
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// fromValue returns v as a JSON number, string, boolean or null
+func fromValue(v d2protocolparser.Value) interface{} {
+	switch v.Type {
+	case "int32", "uint32":
+		return v.Int
+	case "float64":
+		return v.Number
+	case "string":
+		return v.Text
+	case "bool":
+		return v.Bool
+	}
+	return nil
+}
+
+// toValue reads a value of type typ decoded from JSON
+func toValue(typ string, value interface{}) d2protocolparser.Value {
+	v := d2protocolparser.Value{Type: typ}
+	switch x := value.(type) {
+	case float64:
+		if typ == "float64" {
+			v.Number = x
+		} else {
+			v.Int = int64(x)
+		}
+	case string:
+		v.Text = x
+	case bool:
+		v.Bool = x
+	}
+	return v
+}
+
+func fromConstants(c d2protocolparser.Constants) []constant {
+	out := make([]constant, 0, len(c.Values))
+	for _, v := range c.Values {
+		out = append(out, constant{v.Class, v.Name, v.Value.Type, fromValue(v.Value)})
+	}
+	return out
+}
+
+func toConstants(constants []constant) d2protocolparser.Constants {
+	var out d2protocolparser.Constants
+	for _, c := range constants {
+		out.Values = append(out.Values, d2protocolparser.Constant{Class: c.Class, Name: c.Name, Value: toValue(c.Type, c.Value)})
+	}
+	return out
+}
+
+type class struct {
+	Name        string  `json:"name"`
+	Namespace   string  `json:"namespace"`
+	Parent      string  `json:"parent"`
+	ProtocolID  uint16  `json:"protocolId"`
+	UseHashFunc bool    `json:"useHashFunc"`
+	Hash        hash    `json:"hash"`
+	Direction   string  `json:"direction"`
+	Fields      []field `json:"fields"`
+	Boxes       []box   `json:"boxes"`
+}
+
+// box is a BooleanByteWrapper byte, older documents have none and derive
+// them from the fields
+type box struct {
+	Index    int         `json:"index"`
+	Register uint32      `json:"register"`
+	Position int         `json:"position"`
+	Members  []boxMember `json:"members"`
+}
+
+type boxMember struct {
+	Field string `json:"field"`
+	Bit   uint   `json:"bit"`
+}
+
+type hash struct {
+	Function string `json:"function"`
+	Payload  bool   `json:"payload"`
+	Position string `json:"position"`
+}
+
+type field struct {
+	Name              string  `json:"name"`
+	Order             int     `json:"order"`
+	Type              string  `json:"type"`
+	WriteMethod       string  `json:"writeMethod"`
+	Method            string  `json:"method"`
+	IsVector          bool    `json:"isVector"`
+	IsDynamicLength   bool    `json:"isDynamicLength"`
+	Length            uint32  `json:"length"`
+	WriteLengthMethod string  `json:"writeLengthMethod"`
+	UseTypeManager    bool    `json:"useTypeManager"`
+	UseBBW            bool    `json:"useBBW"`
+	BBWPosition       uint    `json:"bbwPosition"`
+	Min               float64 `json:"min"`
+	MinOp             string  `json:"minOp"`
+	Max               float64 `json:"max"`
+	MaxOp             string  `json:"maxOp"`
+}
+
+type enum struct {
+	Name   string      `json:"name"`
+	Values []enumValue `json:"values"`
+}
+
+// enumValue holds its value like constant, schema version 1 documents only
+// have int32 values and no type
+type enumValue struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// directions maps the direction names to their values, older documents have
+// no direction which is unknown
+var directions = map[string]d2protocolparser.Direction{
+	d2protocolparser.DirectionServer.String(): d2protocolparser.DirectionServer,
+	d2protocolparser.DirectionClient.String(): d2protocolparser.DirectionClient,
+}
+
+// hashPositions maps the hash position names to their values, older
+// documents have no hash position which is unknown
+var hashPositions = map[string]d2protocolparser.HashPosition{
+	d2protocolparser.HashPositionPayload.String(): d2protocolparser.HashPositionPayload,
+	d2protocolparser.HashPositionPacket.String():  d2protocolparser.HashPositionPacket,
+}
+
+func fromClasses(classes []d2protocolparser.Class) []class {
+	out := make([]class, 0, len(classes))
+	for _, c := range classes {
+		fields := make([]field, 0, len(c.Fields))
+		for _, f := range c.Fields {
+			fields = append(fields, field{
+				f.Name, f.Order, f.Type, f.WriteMethod, f.Method,
+				f.IsVector, f.IsDynamicLength, f.Length, f.WriteLengthMethod,
+				f.UseTypeManager, f.UseBBW, f.BBWPosition,
+				f.Min, f.MinOp, f.Max, f.MaxOp,
+			})
+		}
+		boxes := make([]box, 0, len(c.Boxes))
+		for _, g := range c.Boxes {
+			members := make([]boxMember, 0, len(g.Members))
+			for _, m := range g.Members {
+				members = append(members, boxMember{m.Field, m.Bit})
+			}
+			boxes = append(boxes, box{g.Index, g.Register, g.Position, members})
+		}
+		out = append(out, class{c.Name, c.Namespace, c.Parent, c.ProtocolID, c.UseHashFunc, hash{c.Hash.Function, c.Hash.Payload, c.Hash.Position.String()}, c.Direction.String(), fields, boxes})
+	}
+	return out
+}
+
+func toClasses(classes []class) []d2protocolparser.Class {
+	var out []d2protocolparser.Class
+	for _, c := range classes {
+		var fields []d2protocolparser.Field
+		for _, f := range c.Fields {
+			fields = append(fields, d2protocolparser.Field{
+				Name:              f.Name,
+				Order:             f.Order,
+				Type:              f.Type,
+				WriteMethod:       f.WriteMethod,
+				Method:            f.Method,
+				IsVector:          f.IsVector,
+				IsDynamicLength:   f.IsDynamicLength,
+				Length:            f.Length,
+				WriteLengthMethod: f.WriteLengthMethod,
+				UseTypeManager:    f.UseTypeManager,
+				UseBBW:            f.UseBBW,
+				BBWPosition:       f.BBWPosition,
+				Min:               f.Min,
+				MinOp:             f.MinOp,
+				Max:               f.Max,
+				MaxOp:             f.MaxOp,
+			})
+		}
+		// documents without order keep their array order
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].Order < fields[j].Order })
+		for i := range fields {
+			fields[i].Order = i
+		}
+		var boxes []d2protocolparser.BBWGroup
+		for _, g := range c.Boxes {
+			var members []d2protocolparser.BBWMember
+			for _, m := range g.Members {
+				members = append(members, d2protocolparser.BBWMember{Field: m.Field, Bit: m.Bit})
+			}
+			boxes = append(boxes, d2protocolparser.BBWGroup{Index: g.Index, Register: g.Register, Position: g.Position, Members: members})
+		}
+		out = append(out, d2protocolparser.Class{
+			Name:        c.Name,
+			Namespace:   c.Namespace,
+			Parent:      c.Parent,
+			Fields:      fields,
+			ProtocolID:  c.ProtocolID,
+			UseHashFunc: c.UseHashFunc,
+			Hash:        d2protocolparser.Hash{Function: c.Hash.Function, Payload: c.Hash.Payload, Position: hashPositions[c.Hash.Position]},
+			Direction:   directions[c.Direction],
+			Boxes:       boxes,
+		})
+	}
+	return out
+}
+
+func fromProtocol(p *d2protocolparser.Protocol) document {
+	v := p.Version
+	enums := make([]enum, 0, len(p.Enums))
+	for _, e := range p.Enums {
+		values := make([]enumValue, 0, len(e.Values))
+		for _, ev := range e.Values {
+			values = append(values, enumValue{ev.Name, ev.Value.Type, fromValue(ev.Value)})
+		}
+		enums = append(enums, enum{e.Name, values})
+	}
+	return document{
+		SchemaVersion: SchemaVersion,
+		Version:       version{v.Major, v.Minor, v.Release, v.Revision, v.Patch},
+		Constants:     fromConstants(p.Constants),
+		Messages:      fromClasses(p.Messages),
+		Types:         fromClasses(p.Types),
+		Enums:         enums,
+	}
+}
+
+func (d document) toProtocol() *d2protocolparser.Protocol {
+	var enums []d2protocolparser.Enum
+	for _, e := range d.Enums {
+		var values []d2protocolparser.EnumValue
+		for _, ev := range e.Values {
+			if ev.Type == "" {
+				ev.Type = "int32"
+			}
+			values = append(values, d2protocolparser.EnumValue{Name: ev.Name, Value: toValue(ev.Type, ev.Value)})
+		}
+		enums = append(enums, d2protocolparser.Enum{Name: e.Name, Values: values})
+	}
+	v := d.Version
+	return &d2protocolparser.Protocol{
+		Messages:  toClasses(d.Messages),
+		Types:     toClasses(d.Types),
+		Enums:     enums,
+		Version:   d2protocolparser.Version{Major: v.Major, Minor: v.Minor, Release: v.Release, Revision: v.Revision, Patch: v.Patch},
+		Constants: toConstants(d.Constants),
+	}
+}
+
+// Marshal returns the JSON document of p
+func Marshal(p *d2protocolparser.Protocol) ([]byte, error) {
+	return json.MarshalIndent(fromProtocol(p), "", "  ")
+}
+
+// Unmarshal reads a Protocol from a JSON document
+func Unmarshal(data []byte) (*d2protocolparser.Protocol, error) {
+	var d document
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return d.checked()
+}
+
+// Encode writes the JSON document of p to w
+func Encode(w io.Writer, p *d2protocolparser.Protocol) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(fromProtocol(p))
+}
+
+// Decode reads a Protocol from a JSON document read from r
+func Decode(r io.Reader) (*d2protocolparser.Protocol, error) {
+	var d document
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return nil, err
+	}
+	return d.checked()
+}
+
+func (d document) checked() (*d2protocolparser.Protocol, error) {
+	if d.SchemaVersion < 1 || d.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("%v: %w", d.SchemaVersion, ErrUnsupportedSchema)
+	}
+	return d.toProtocol(), nil
+}
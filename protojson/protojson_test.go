@@ -0,0 +1,266 @@
+package protojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kelvyne/d2protocolparser"
+)
+
+func testProtocol() *d2protocolparser.Protocol {
+	return &d2protocolparser.Protocol{
+		Messages: []d2protocolparser.Class{
+			{
+				Name:       "IdentificationMessage",
+				Namespace:  "com.ankamagames.dofus.network.messages.connection",
+				ProtocolID: 4,
+				Fields: []d2protocolparser.Field{
+					{Name: "version", Type: "VersionExtended"},
+					{Name: "credentials", Order: 1, Type: "int8", WriteMethod: "writeByte", Method: "Int8", IsVector: true, IsDynamicLength: true, WriteLengthMethod: "writeVarInt"},
+					{Name: "autoconnect", Order: 2, Type: "bool", UseBBW: true, BBWPosition: 1},
+				},
+				Boxes: []d2protocolparser.BBWGroup{{Index: 2, Register: 2, Position: 3, Members: []d2protocolparser.BBWMember{{Field: "autoconnect", Bit: 1}}}},
+			},
+			{
+				Name:        "HelloGameMessage",
+				Namespace:   "com.ankamagames.dofus.network.messages.game.approach",
+				ProtocolID:  101,
+				UseHashFunc: true,
+				Hash:        d2protocolparser.Hash{Function: "HASH_FUNCTION", Payload: true, Position: d2protocolparser.HashPositionPayload},
+				Direction:   d2protocolparser.DirectionServer,
+			},
+		},
+		Types: []d2protocolparser.Class{
+			{
+				Name:       "GameContextActorInformations",
+				Namespace:  "com.ankamagames.dofus.network.types.game.context",
+				Parent:     "GameContextBasicInformations",
+				ProtocolID: 150,
+				Fields: []d2protocolparser.Field{
+					{Name: "disposition", Type: "EntityDispositionInformations", UseTypeManager: true},
+					{Name: "position", Order: 1, Type: "int16", WriteMethod: "writeShort", Method: "Int16", IsVector: true, Length: 2, Min: -1, MinOp: "<", Max: 560, MaxOp: ">="},
+				},
+			},
+		},
+		Enums: []d2protocolparser.Enum{
+			{Name: "AlignmentSideEnum", Values: []d2protocolparser.EnumValue{{Name: "ALIGNMENT_UNKNOWN", Value: d2protocolparser.Value{Type: "int32", Int: -2}}}},
+			{Name: "EmptyEnum"},
+			{Name: "ServerTypeEnum", Values: []d2protocolparser.EnumValue{
+				{Name: "RELEASE", Value: d2protocolparser.Value{Type: "string", Text: "release"}},
+				{Name: "MAX_ID", Value: d2protocolparser.Value{Type: "uint32", Int: 4294967295}},
+				{Name: "RATIO", Value: d2protocolparser.Value{Type: "float64", Number: 0.5}},
+			}},
+		},
+		Version: d2protocolparser.Version{Major: 2, Minor: 42, Revision: 1027565},
+		Constants: d2protocolparser.Constants{Values: []d2protocolparser.Constant{
+			{Class: "ProtocolConstantsEnum", Name: "PROTOCOL_BUILD", Value: d2protocolparser.Value{Type: "int32", Int: 1658}},
+			{Class: "ProtocolConstantsEnum", Name: "MIN_LOGIN_LEN", Value: d2protocolparser.Value{Type: "uint32", Int: 3}},
+			{Class: "Metadata", Name: "PROTOCOL_DATE", Value: d2protocolparser.Value{Type: "string", Text: "Mon, 05 Jun 2017"}},
+			{Class: "Metadata", Name: "PROTOCOL_VISIBILITY", Value: d2protocolparser.Value{Type: "bool", Bool: true}},
+			{Class: "Metadata", Name: "RATIO", Value: d2protocolparser.Value{Type: "float64", Number: 0.5}},
+			{Class: "Metadata", Name: "NONE", Value: d2protocolparser.Value{Type: "null"}},
+		}},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	p := testProtocol()
+	data, err := Marshal(p)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("Unmarshal() = %v, want %v", got, p)
+	}
+
+	var buf bytes.Buffer
+	if err = Encode(&buf, p); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if got, err = Decode(&buf); err != nil || !reflect.DeepEqual(got, p) {
+		t.Errorf("Decode() = %v, %v, want %v", got, err, p)
+	}
+}
+
+func TestUnmarshal_FieldOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		want   []string
+	}{
+		{"ordered", `[{"name": "b", "order": 1}, {"name": "a", "order": 0}]`, []string{"a", "b"}},
+		{"no order", `[{"name": "b"}, {"name": "a"}]`, []string{"b", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := `{"schemaVersion": 1, "messages": [{"name": "M", "fields": ` + tt.fields + `}]}`
+			p, err := Unmarshal([]byte(doc))
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			var got []string
+			for i, f := range p.Messages[0].Fields {
+				if f.Order != i {
+					t.Errorf("%v: Order = %v, want %v", f.Name, f.Order, i)
+				}
+				got = append(got, f.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_SchemaVersion(t *testing.T) {
+	for _, doc := range []string{`{"schemaVersion": 0}`, `{"schemaVersion": 3}`} {
+		if _, err := Unmarshal([]byte(doc)); !errors.Is(err, ErrUnsupportedSchema) {
+			t.Errorf("%v: expected ErrUnsupportedSchema, got %v", doc, err)
+		}
+	}
+}
+
+func TestUnmarshal_SchemaVersion1(t *testing.T) {
+	doc := `{
+		"schemaVersion": 1,
+		"version": {"major": 2, "minor": 42, "release": 0, "revision": 1, "patch": 0},
+		"messages": [{
+			"name": "M", "namespace": "ns", "parent": "", "protocolId": 1, "useHashFunc": false,
+			"fields": [{
+				"name": "a", "type": "int8", "writeMethod": "writeByte", "method": "Int8",
+				"isVector": false, "isDynamicLength": false, "length": 0, "writeLengthMethod": "",
+				"useTypeManager": false, "useBBW": false, "bbwPosition": 0
+			}]
+		}],
+		"types": [],
+		"enums": [{"name": "E", "values": [{"name": "A", "value": -2}]}]
+	}`
+	schema := readSchema(t, "schema.json")
+	var v interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatal(err)
+	}
+	if err := validate(schema, schema, v, "$"); err != nil {
+		t.Fatalf("document does not match schema.json: %v", err)
+	}
+
+	p, err := Unmarshal([]byte(doc))
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := []d2protocolparser.EnumValue{{Name: "A", Value: d2protocolparser.Value{Type: "int32", Int: -2}}}
+	if !reflect.DeepEqual(p.Enums[0].Values, want) {
+		t.Errorf("values = %v, want %v", p.Enums[0].Values, want)
+	}
+	if p.Messages[0].Direction != d2protocolparser.DirectionUnknown {
+		t.Errorf("Direction = %v, want %v", p.Messages[0].Direction, d2protocolparser.DirectionUnknown)
+	}
+}
+
+// validate checks v against the subset of JSON Schema used by the schemas
+func validate(root, schema map[string]interface{}, v interface{}, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/definitions/")
+		return validate(root, root["definitions"].(map[string]interface{})[name].(map[string]interface{}), v, path)
+	}
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, v) {
+		return fmt.Errorf("%v: %v instead of %v", path, v, c)
+	}
+	if values, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range values {
+			found = found || reflect.DeepEqual(e, v)
+		}
+		if !found {
+			return fmt.Errorf("%v: %v not in %v", path, v, values)
+		}
+	}
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%v: not an object", path)
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				if _, ok := obj[r.(string)]; !ok {
+					return fmt.Errorf("%v: missing %v", path, r)
+				}
+			}
+		}
+		for k, e := range obj {
+			s, ok := props[k]
+			if !ok {
+				return fmt.Errorf("%v: unexpected %v", path, k)
+			}
+			if err := validate(root, s.(map[string]interface{}), e, path+"."+k); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%v: not an array", path)
+		}
+		for i, e := range arr {
+			if err := validate(root, schema["items"].(map[string]interface{}), e, fmt.Sprintf("%v[%v]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%v: not a string", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%v: not a boolean", path)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%v: not a number", path)
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%v: not an integer", path)
+		}
+	}
+	return nil
+}
+
+func readSchema(t *testing.T, name string) map[string]interface{} {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err = json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("invalid %v: %v", name, err)
+	}
+	return schema
+}
+
+func TestMarshal_Schema(t *testing.T) {
+	schema := readSchema(t, "schema-v2.json")
+	doc, err := Marshal(testProtocol())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err = json.Unmarshal(doc, &v); err != nil {
+		t.Fatal(err)
+	}
+	if err = validate(schema, schema, v, "$"); err != nil {
+		t.Errorf("document does not match schema-v2.json: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package d2protocolparser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kelvyne/as3"
+	"github.com/kelvyne/as3/bytecode"
+)
+
+// Direction tells which side of the connection sends a message
+type Direction int
+
+const (
+	// DirectionUnknown is used when the MessageReceiver class was not found,
+	// or for messages both received and constructed by the client, or neither
+	DirectionUnknown Direction = iota
+	// DirectionServer messages are listed by the MessageReceiver and never
+	// constructed by the client code, only the server sends them
+	DirectionServer
+	// DirectionClient messages are constructed by the client code and not
+	// listed by the MessageReceiver, only the client sends them
+	DirectionClient
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionUnknown:
+		return "unknown"
+	case DirectionServer:
+		return "server"
+	case DirectionClient:
+		return "client"
+	}
+	return fmt.Sprintf("Direction(%d)", int(d))
+}
+
+const (
+	receiverNamespace = "com.ankamagames.dofus.network"
+	receiverName      = "MessageReceiver"
+)
+
+// classMethods returns every method of a class, its static initializer
+// included
+func (b *builder) classMethods(abc *as3.AbcFile, class as3.Class) []as3.Method {
+	methods := []as3.Method{abc.Methods[class.ClassInfo.CInit]}
+	for _, t := range class.ClassTraits.Methods {
+		methods = append(methods, abc.Methods[t.Source.Method])
+	}
+	for _, t := range class.InstanceTraits.Methods {
+		methods = append(methods, abc.Methods[t.Source.Method])
+	}
+	return methods
+}
+
+// extractMessageReceiver returns the names of the messages registered by the
+// MessageReceiver class of the first abc file defining it. The second result
+// is false when no abc file defines it.
+func (b *builder) extractMessageReceiver() (map[string]bool, bool, error) {
+	for _, ac := range b.classes() {
+		class := ac.class
+		if class.Namespace != receiverNamespace || class.Name != receiverName {
+			continue
+		}
+		names := map[string]bool{}
+		for _, m := range b.classMethods(ac.abc, class) {
+			if err := m.BodyInfo.Disassemble(); err != nil {
+				return nil, true, newExtractError(class.Name, class.Namespace, "", err)
+			}
+			for _, instr := range m.BodyInfo.Instructions {
+				if name, ok := b.referencedMessage(ac.abc, instr); ok {
+					names[name] = true
+				}
+			}
+		}
+		return names, true, nil
+	}
+	return nil, false, nil
+}
+
+// extractSentMessages returns the names of the messages constructed by the
+// client code, the classes of the network package excepted. Methods that
+// cannot be disassembled are ignored.
+func (b *builder) extractSentMessages() map[string]bool {
+	names := map[string]bool{}
+	for _, ac := range b.classes() {
+		class := ac.class
+		if class.Namespace == receiverNamespace || strings.HasPrefix(class.Namespace, receiverNamespace+".") {
+			continue
+		}
+		for _, m := range b.classMethods(ac.abc, class) {
+			if m.BodyInfo == nil || m.BodyInfo.Disassemble() != nil {
+				continue
+			}
+			for _, instr := range m.BodyInfo.Instructions {
+				if instr.Model.Name != "constructprop" {
+					continue
+				}
+				if name, ok := b.referencedMessage(ac.abc, instr); ok {
+					names[name] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// referencedMessage returns the name of the message class an instruction
+// refers to, such as the getlex of _messagesTypes[id] = Message
+func (b *builder) referencedMessage(abc *as3.AbcFile, instr bytecode.Instr) (string, bool) {
+	if !multinameOperandInstrs[instr.Model.Name] || len(instr.Operands) == 0 {
+		return "", false
+	}
+	pool := abc.Source.ConstantPool
+	multiname := pool.Multinames[instr.Operands[0]]
+	if multiname.Kind != bytecode.MultinameKindQName {
+		return "", false
+	}
+	ns := pool.Namespaces[multiname.Namespace]
+	if !strings.HasPrefix(pool.Strings[ns.Name], messagePrefix) {
+		return "", false
+	}
+	return pool.Strings[multiname.Name], true
+}
+
+// setDirections sets the Direction of the messages from the MessageReceiver
+// registry and the messages constructed by the client code. Directions are
+// left unknown when the registry is not found.
+func (b *builder) setDirections(messages []Class) error {
+	received, found, err := b.extractMessageReceiver()
+	if err != nil || !found {
+		return err
+	}
+	sent := b.extractSentMessages()
+	for i := range messages {
+		name := messages[i].Name
+		switch {
+		case received[name] && !sent[name]:
+			messages[i].Direction = DirectionServer
+		case sent[name] && !received[name]:
+			messages[i].Direction = DirectionClient
+		default:
+			messages[i].Direction = DirectionUnknown
+		}
+	}
+	return nil
+}
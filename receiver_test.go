@@ -0,0 +1,54 @@
+package d2protocolparser
+
+import (
+	"testing"
+
+	"github.com/kelvyne/as3"
+)
+
+func TestDirection_String(t *testing.T) {
+	tests := []struct {
+		d    Direction
+		want string
+	}{
+		{DirectionUnknown, "unknown"},
+		{DirectionServer, "server"},
+		{DirectionClient, "client"},
+		{Direction(9), "Direction(9)"},
+	}
+	for _, tt := range tests {
+		if got := tt.d.String(); got != tt.want {
+			t.Errorf("Direction.String() = %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func Test_builder_setDirections(t *testing.T) {
+	abc := open(t)
+	b := &builder{abcFiles: []*as3.AbcFile{abc}}
+	messages := []Class{
+		{Name: "HelloGameMessage"},
+		{Name: "IdentificationMessage"},
+		{Name: "UnusedMessage"},
+	}
+	if err := b.setDirections(messages); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	want := []Direction{DirectionServer, DirectionClient, DirectionUnknown}
+	for i, m := range messages {
+		if m.Direction != want[i] {
+			t.Errorf("%v: Direction = %v, want %v", m.Name, m.Direction, want[i])
+		}
+	}
+}
+
+func Test_builder_setDirections_NoReceiver(t *testing.T) {
+	b := &builder{}
+	messages := []Class{{Name: "HelloGameMessage"}}
+	if err := b.setDirections(messages); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if messages[0].Direction != DirectionUnknown {
+		t.Errorf("Direction = %v, want %v", messages[0].Direction, DirectionUnknown)
+	}
+}
@@ -0,0 +1,118 @@
+package d2protocolparser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// Container is the compression format of a swf file, given by its signature
+type Container string
+
+// Swf containers
+const (
+	ContainerFWS Container = "FWS" // ContainerFWS is an uncompressed swf
+	ContainerCWS Container = "CWS" // ContainerCWS is a zlib-compressed swf
+	ContainerZWS Container = "ZWS" // ContainerZWS is a LZMA-compressed swf
+)
+
+func (c Container) String() string {
+	switch c {
+	case ContainerFWS:
+		return "uncompressed FWS"
+	case ContainerCWS:
+		return "zlib-compressed CWS"
+	case ContainerZWS:
+		return "LZMA-compressed ZWS"
+	}
+	return fmt.Sprintf("unknown %q", string(c))
+}
+
+// ErrSwfUnknownContainer means that the file does not start with a swf signature
+var ErrSwfUnknownContainer = errors.New("unknown swf signature")
+
+// swfHeaderLen is the length of the signature, version and file length
+const swfHeaderLen = 8
+
+// DetectContainer returns the container of the swf file starting with data
+func DetectContainer(data []byte) (Container, error) {
+	if len(data) < 3 {
+		return "", ErrSwfUnknownContainer
+	}
+	c := Container(data[:3])
+	switch c {
+	case ContainerFWS, ContainerCWS, ContainerZWS:
+		return c, nil
+	}
+	return "", ErrSwfUnknownContainer
+}
+
+// decompressSwf returns the uncompressed FWS version of a swf file and the
+// container it was found in
+func decompressSwf(data []byte) ([]byte, Container, error) {
+	c, err := DetectContainer(data)
+	if err != nil {
+		return nil, c, err
+	}
+	if c == ContainerFWS {
+		return data, c, nil
+	}
+	body, err := decompress(c, data)
+	if err != nil {
+		return nil, c, fmt.Errorf("%v: %v", c, err)
+	}
+	return body, c, nil
+}
+
+func decompress(c Container, data []byte) ([]byte, error) {
+	if len(data) < swfHeaderLen {
+		return nil, io.ErrUnexpectedEOF
+	}
+	// the file length of the header is the length of the uncompressed file
+	fileLen := binary.LittleEndian.Uint32(data[4:8])
+	if fileLen < swfHeaderLen {
+		return nil, fmt.Errorf("invalid swf length %v", fileLen)
+	}
+
+	var r io.Reader
+	var err error
+	switch c {
+	case ContainerCWS:
+		if r, err = zlib.NewReader(bytes.NewReader(data[swfHeaderLen:])); err != nil {
+			return nil, err
+		}
+	case ContainerZWS:
+		// the header is followed by the compressed length (4 bytes), the LZMA
+		// properties (5 bytes) and the LZMA stream. The .lzma header expected
+		// by the decoder is the properties followed by the uncompressed size.
+		const zwsHeaderLen = swfHeaderLen + 4 + 5
+		if len(data) < zwsHeaderLen {
+			return nil, io.ErrUnexpectedEOF
+		}
+		header := make([]byte, 13)
+		copy(header, data[swfHeaderLen+4:zwsHeaderLen])
+		binary.LittleEndian.PutUint64(header[5:], uint64(fileLen-swfHeaderLen))
+		stream := io.MultiReader(bytes.NewReader(header), bytes.NewReader(data[zwsHeaderLen:]))
+		if r, err = lzma.NewReader(stream); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if uint32(len(body)) != fileLen-swfHeaderLen {
+		return nil, fmt.Errorf("decompressed %v bytes instead of %v", len(body), fileLen-swfHeaderLen)
+	}
+	out := make([]byte, 0, fileLen)
+	out = append(out, ContainerFWS...)
+	out = append(out, data[3:swfHeaderLen]...)
+	return append(out, body...), nil
+}
@@ -0,0 +1,119 @@
+package d2protocolparser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// testFWS returns a minimal uncompressed swf: an empty 1x1 stage with a
+// single frame and an End tag
+func testFWS() []byte {
+	body := []byte{
+		0x08, 0x00, 0x00, 0x00, 0x00, // RECT with 1-bit fields
+		0x00, 0x18, // frame rate
+		0x01, 0x00, // frame count
+		0x00, 0x00, // End tag
+	}
+	fws := []byte{'F', 'W', 'S', 10, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(fws[4:], uint32(len(fws)+len(body)))
+	return append(fws, body...)
+}
+
+func testCWS(t *testing.T, fws []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(fws[swfHeaderLen:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cws := append([]byte{'C'}, fws[1:swfHeaderLen]...)
+	return append(cws, buf.Bytes()...)
+}
+
+func testZWS(t *testing.T, fws []byte) []byte {
+	body := fws[swfHeaderLen:]
+	var buf bytes.Buffer
+	w, err := lzma.WriterConfig{Size: int64(len(body))}.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(body); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// .lzma: properties (5 bytes), uncompressed size (8 bytes), stream
+	stream := buf.Bytes()
+	zws := append([]byte{'Z'}, fws[1:swfHeaderLen]...)
+	zws = append(zws, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(zws[swfHeaderLen:], uint32(len(stream)-13))
+	zws = append(zws, stream[:5]...)
+	return append(zws, stream[13:]...)
+}
+
+func TestDecompressSwf(t *testing.T) {
+	fws := testFWS()
+	cws := testCWS(t, fws)
+	zws := testZWS(t, fws)
+
+	tests := []struct {
+		name          string
+		data          []byte
+		wantContainer Container
+		wantErr       bool
+	}{
+		{"FWS", fws, ContainerFWS, false},
+		{"CWS", cws, ContainerCWS, false},
+		{"ZWS", zws, ContainerZWS, false},
+		{"truncated CWS", cws[:len(cws)-4], ContainerCWS, true},
+		{"truncated ZWS", zws[:14], ContainerZWS, true},
+		{"unknown", []byte("GIF89a"), "", true},
+		{"empty", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, c, err := decompressSwf(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decompressSwf() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if c != tt.wantContainer {
+				t.Errorf("decompressSwf() container = %v, want %v", c, tt.wantContainer)
+			}
+			if !tt.wantErr && !bytes.Equal(got, fws) {
+				t.Errorf("decompressSwf() = %x, want %x", got, fws)
+			}
+		})
+	}
+}
+
+func Test_parseSwf(t *testing.T) {
+	fws := testFWS()
+	tests := []struct {
+		name string
+		data []byte
+		want Container
+	}{
+		{"FWS", fws, ContainerFWS},
+		{"CWS", testCWS(t, fws), ContainerCWS},
+		{"ZWS", testZWS(t, fws), ContainerZWS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, c, err := parseSwf(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("parseSwf() error = %v", err)
+			}
+			if c != tt.want {
+				t.Errorf("parseSwf() container = %v, want %v", c, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package d2protocolparser
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoTypeManager means that a field is not serialized through the
+// ProtocolTypeManager
+var ErrNoTypeManager = errors.New("field does not use the TypeManager")
+
+// ErrUnknownType means that the declared type of a field is not a type of
+// the Protocol
+var ErrUnknownType = errors.New("unknown type")
+
+// TypeByID returns the type the ProtocolTypeManager instantiates for a type
+// id. The ProtocolTypeManager registers every type under its protocolId, so
+// the registry is derived from Class.ProtocolID. The first type is returned
+// when several share an id.
+func (p *Protocol) TypeByID(id uint16) (*Class, bool) {
+	for i := range p.Types {
+		if p.Types[i].ProtocolID == id {
+			return &p.Types[i], true
+		}
+	}
+	return nil, false
+}
+
+// TypeRegistry returns the ProtocolTypeManager registry, every type indexed
+// by its type id
+func (p *Protocol) TypeRegistry() map[uint16]*Class {
+	registry := make(map[uint16]*Class, len(p.Types))
+	for i := len(p.Types) - 1; i >= 0; i-- {
+		registry[p.Types[i].ProtocolID] = &p.Types[i]
+	}
+	return registry
+}
+
+// AllowedTypes returns the types a TypeManager field can hold: its declared
+// type followed by every type inheriting from it
+func (p *Protocol) AllowedTypes(f Field) ([]*Class, error) {
+	if !f.UseTypeManager {
+		return nil, fmt.Errorf("%v: %w", f.Name, ErrNoTypeManager)
+	}
+	var declared *Class
+	for i := range p.Types {
+		if p.Types[i].Name == f.Type {
+			declared = &p.Types[i]
+			break
+		}
+	}
+	if declared == nil {
+		return nil, fmt.Errorf("%v: %w %v", f.Name, ErrUnknownType, f.Type)
+	}
+	allowed := []*Class{declared}
+	for _, c := range p.Subclasses(f.Type) {
+		if isType(p, c) {
+			allowed = append(allowed, c)
+		}
+	}
+	return allowed, nil
+}
+
+// isType reports whether c points into p.Types
+func isType(p *Protocol, c *Class) bool {
+	for i := range p.Types {
+		if &p.Types[i] == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package d2protocolparser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestProtocol_TypeByID(t *testing.T) {
+	p := inheritanceProtocol()
+	p.Types[0].ProtocolID = 150
+	p.Types[3].ProtocolID = 143
+	if c, ok := p.TypeByID(143); !ok || c != &p.Types[3] {
+		t.Errorf("Protocol.TypeByID(143) = %v, %v, want GameFightFighterInformations", c, ok)
+	}
+	if _, ok := p.TypeByID(1); ok {
+		t.Errorf("expected type id 1 to be unknown")
+	}
+	if r := p.TypeRegistry(); r[150] != &p.Types[0] || r[143] != &p.Types[3] {
+		t.Errorf("Protocol.TypeRegistry() = %v", r)
+	}
+}
+
+func TestProtocol_AllowedTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   Field
+		want    []string
+		wantErr error
+	}{
+		{
+			"subclasses",
+			Field{Name: "actor", Type: "GameContextActorInformations", UseTypeManager: true},
+			[]string{"GameContextActorInformations", "GameRolePlayActorInformations", "GameRolePlayNamedActorInformations", "GameFightFighterInformations"},
+			nil,
+		},
+		{"leaf", Field{Name: "actor", Type: "GameFightFighterInformations", UseTypeManager: true}, []string{"GameFightFighterInformations"}, nil},
+		{"no type manager", Field{Name: "actor", Type: "GameFightFighterInformations"}, nil, ErrNoTypeManager},
+		{"message", Field{Name: "m", Type: "IdentificationSuccessMessage", UseTypeManager: true}, nil, ErrUnknownType},
+	}
+	p := inheritanceProtocol()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := p.AllowedTypes(tt.field)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Protocol.AllowedTypes() error = %v, want %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, c := range allowed {
+				got = append(got, c.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Protocol.AllowedTypes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -13,33 +13,105 @@ var ErrVerifyNoStaticLength = errors.New("vector field has no length")
 // has no write method set
 var ErrVerifyScalarNoWrite = errors.New("scalar type has no write method")
 
-type verifyError struct {
-	err error
-	c   Class
-	f   Field
+// ErrVerifyBBWOverlap means that two flags of a BooleanByteWrapper byte use
+// the same bit
+var ErrVerifyBBWOverlap = errors.New("BooleanByteWrapper bit is used twice")
+
+// ErrVerifyBBWNotContiguous means that the flags of a BooleanByteWrapper byte
+// skip a bit or are separated by another field
+var ErrVerifyBBWNotContiguous = errors.New("BooleanByteWrapper flags are not contiguous")
+
+// ErrVerifyBBWNotWritten means that a BooleanByteWrapper byte is never written
+// by the serialize method
+var ErrVerifyBBWNotWritten = errors.New("BooleanByteWrapper byte is not written")
+
+// VerifyError is returned by Verify and tells which Field of which Class
+// is not well-formed
+type VerifyError struct {
+	Err   error
+	Class Class
+	Field Field
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("%v:%v : %v", e.Class.Name, e.Field.Name, e.Err)
 }
 
-func (e verifyError) Error() string {
-	return fmt.Sprintf("%v:%v : %v", e.c.Name, e.f.Name, e.err)
+// Unwrap returns the underlying cause
+func (e *VerifyError) Unwrap() error {
+	return e.Err
 }
 
 // Verify checks that a Protocol is well-formed and that it is complete
 func Verify(p *Protocol) error {
+	if errs := verifyAll(p); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// verifyAll returns every bad field of the Protocol instead of the first one
+func verifyAll(p *Protocol) []*VerifyError {
+	var errs []*VerifyError
 	for _, t := range p.Types {
-		if err := verifyClass(t); err != nil {
-			return err
+		errs = append(errs, verifyClass(t)...)
+	}
+	for _, m := range p.Messages {
+		errs = append(errs, verifyBBW(m)...)
+	}
+	for _, t := range p.Types {
+		errs = append(errs, verifyBBW(t)...)
+	}
+	return errs
+}
+
+// verifyBBW checks that each BooleanByteWrapper byte uses the bits from 0 in
+// order, that its flags follow each other in the fields and that it is written
+// right after them
+func verifyBBW(c Class) []*VerifyError {
+	index := map[string]int{}
+	for i, f := range c.Fields {
+		index[f.Name] = i
+	}
+	var errs []*VerifyError
+	grouped := map[string]bool{}
+	for _, g := range c.BBWGroups() {
+		used := map[uint]bool{}
+		for k, m := range g.Members {
+			i, ok := index[m.Field]
+			field := Field{Name: m.Field}
+			if ok {
+				field = c.Fields[i]
+			}
+			var err error
+			switch {
+			case used[m.Bit] || grouped[m.Field]:
+				err = ErrVerifyBBWOverlap
+			case !ok || m.Bit != uint(k) || i != g.Index+k:
+				err = ErrVerifyBBWNotContiguous
+			case k == len(g.Members)-1 && g.Position == -1:
+				err = ErrVerifyBBWNotWritten
+			case k == len(g.Members)-1 && g.Position != g.Index+len(g.Members):
+				err = ErrVerifyBBWNotContiguous
+			}
+			if err != nil {
+				errs = append(errs, &VerifyError{err, c, field})
+			}
+			used[m.Bit] = true
+			grouped[m.Field] = true
 		}
 	}
-	return nil
+	return errs
 }
 
-func verifyClass(c Class) error {
+func verifyClass(c Class) []*VerifyError {
+	var errs []*VerifyError
 	for _, f := range c.Fields {
 		if err := verifyField(f); err != nil {
-			return verifyError{err, c, f}
+			errs = append(errs, &VerifyError{err, c, f})
 		}
 	}
-	return nil
+	return errs
 }
 
 func verifyField(f Field) error {
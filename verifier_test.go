@@ -0,0 +1,90 @@
+package d2protocolparser
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_verifyBBW(t *testing.T) {
+	bbw := func(name string, pos uint) Field {
+		return Field{Name: name, Type: "bool", UseBBW: true, BBWPosition: pos}
+	}
+	tests := []struct {
+		name   string
+		fields []Field
+		want   map[string]error
+	}{
+		{"contiguous", []Field{{Name: "x", Type: "int8"}, bbw("a", 0), bbw("b", 1)}, map[string]error{}},
+		{"overlap", []Field{bbw("a", 0), bbw("b", 1), bbw("c", 1)}, map[string]error{"c": ErrVerifyBBWOverlap}},
+		{"gap", []Field{bbw("a", 0), bbw("b", 2)}, map[string]error{"b": ErrVerifyBBWNotContiguous}},
+		{"not from 0", []Field{bbw("a", 1)}, map[string]error{"a": ErrVerifyBBWNotContiguous}},
+		{"split", []Field{bbw("a", 0), {Name: "x", Type: "int8"}, bbw("b", 1)}, map[string]error{"b": ErrVerifyBBWNotContiguous}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]error{}
+			for _, e := range verifyBBW(Class{Name: "C", Fields: tt.fields}) {
+				got[e.Field.Name] = e.Err
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("verifyBBW() = %v, want %v", got, tt.want)
+			}
+			for name, want := range tt.want {
+				if !errors.Is(got[name], want) {
+					t.Errorf("%v: got %v, want %v", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+func Test_verifyBBW_Boxes(t *testing.T) {
+	fields := []Field{
+		{Name: "a", Type: "bool", UseBBW: true},
+		{Name: "b", Type: "bool", UseBBW: true, BBWPosition: 1},
+		{Name: "x", Type: "int8"},
+	}
+	box := func(index, position int, members ...BBWMember) BBWGroup {
+		return BBWGroup{Index: index, Register: 2, Position: position, Members: members}
+	}
+	tests := []struct {
+		name  string
+		boxes []BBWGroup
+		want  map[string]error
+	}{
+		{"written", []BBWGroup{box(0, 2, BBWMember{"a", 0}, BBWMember{"b", 1})}, map[string]error{}},
+		{"not written", []BBWGroup{box(0, -1, BBWMember{"a", 0}, BBWMember{"b", 1})}, map[string]error{"b": ErrVerifyBBWNotWritten}},
+		{"written late", []BBWGroup{box(0, 3, BBWMember{"a", 0}, BBWMember{"b", 1})}, map[string]error{"b": ErrVerifyBBWNotContiguous}},
+		{"two registers", []BBWGroup{box(0, 1, BBWMember{"a", 0}), box(1, 2, BBWMember{"b", 1})}, map[string]error{"b": ErrVerifyBBWNotContiguous}},
+		{"two bytes", []BBWGroup{box(0, 2, BBWMember{"a", 0}, BBWMember{"b", 1}), box(1, 2, BBWMember{"b", 0})}, map[string]error{"b": ErrVerifyBBWOverlap}},
+		{"unknown field", []BBWGroup{box(0, 1, BBWMember{"c", 0})}, map[string]error{"c": ErrVerifyBBWNotContiguous}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]error{}
+			for _, e := range verifyBBW(Class{Name: "C", Fields: fields, Boxes: tt.boxes}) {
+				got[e.Field.Name] = e.Err
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("verifyBBW() = %v, want %v", got, tt.want)
+			}
+			for name, want := range tt.want {
+				if !errors.Is(got[name], want) {
+					t.Errorf("%v: got %v, want %v", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerify_BBWMessage(t *testing.T) {
+	p := &Protocol{Messages: []Class{{Name: "M", Fields: []Field{
+		{Name: "a", Type: "bool", UseBBW: true, BBWPosition: 0},
+		{Name: "b", Type: "bool", UseBBW: true, BBWPosition: 1},
+		{Name: "c", Type: "bool", UseBBW: true, BBWPosition: 1},
+	}}}}
+	var e *VerifyError
+	if err := Verify(p); !errors.As(err, &e) || e.Class.Name != "M" || e.Field.Name != "c" {
+		t.Errorf("expected a VerifyError on M.c, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+// Package wire implements the primitive types of the Dofus 2 binary format
+// (ICustomDataInput and ICustomDataOutput in the client).
+//
+// Method names follow the values of Field.Method so that Read+Method and
+// Write+Method can be used to (de)serialize a scalar field.
+package wire
+
+import (
+	"errors"
+	"io"
+	"math"
+)
+
+// ErrVarTooLong means that a variable-length integer is longer than its type
+var ErrVarTooLong = errors.New("variable-length integer too long")
+
+// Reader reads Dofus 2 primitive types from a byte slice
+type Reader struct {
+	data []byte
+	off  int
+}
+
+// NewReader returns a Reader reading from data
+func NewReader(data []byte) *Reader {
+	return &Reader{data: data}
+}
+
+// Len returns the number of bytes that have not been read yet
+func (r *Reader) Len() int {
+	return len(r.data) - r.off
+}
+
+// Offset returns the number of bytes already read
+func (r *Reader) Offset() int {
+	return r.off
+}
+
+// ReadBytes reads n raw bytes. The returned slice aliases the reader buffer.
+func (r *Reader) ReadBytes(n int) ([]byte, error) {
+	if n < 0 || r.Len() < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b, nil
+}
+
+// ReadBoolean reads a boolean stored on a single byte
+func (r *Reader) ReadBoolean() (bool, error) {
+	v, err := r.ReadUInt8()
+	return v != 0, err
+}
+
+// ReadInt8 reads a signed byte (readByte)
+func (r *Reader) ReadInt8() (int8, error) {
+	v, err := r.ReadUInt8()
+	return int8(v), err
+}
+
+// ReadUInt8 reads an unsigned byte (readUnsignedByte)
+func (r *Reader) ReadUInt8() (uint8, error) {
+	b, err := r.ReadBytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// ReadInt16 reads a big-endian signed short (readShort)
+func (r *Reader) ReadInt16() (int16, error) {
+	v, err := r.ReadUInt16()
+	return int16(v), err
+}
+
+// ReadUInt16 reads a big-endian unsigned short (readUnsignedShort)
+func (r *Reader) ReadUInt16() (uint16, error) {
+	b, err := r.ReadBytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(b[0])<<8 | uint16(b[1]), nil
+}
+
+// ReadInt32 reads a big-endian signed int (readInt)
+func (r *Reader) ReadInt32() (int32, error) {
+	v, err := r.ReadUInt32()
+	return int32(v), err
+}
+
+// ReadUInt32 reads a big-endian unsigned int (readUnsignedInt)
+func (r *Reader) ReadUInt32() (uint32, error) {
+	b, err := r.ReadBytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]), nil
+}
+
+// ReadFloat reads a big-endian IEEE 754 single precision number
+func (r *Reader) ReadFloat() (float32, error) {
+	v, err := r.ReadUInt32()
+	return math.Float32frombits(v), err
+}
+
+// ReadDouble reads a big-endian IEEE 754 double precision number
+func (r *Reader) ReadDouble() (float64, error) {
+	b, err := r.ReadBytes(8)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, x := range b {
+		v = v<<8 | uint64(x)
+	}
+	return math.Float64frombits(v), nil
+}
+
+// ReadString reads an UTF-8 string prefixed by its unsigned short length (readUTF)
+func (r *Reader) ReadString() (string, error) {
+	n, err := r.ReadUInt16()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.ReadBytes(int(n))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (r *Reader) readVar(bits uint) (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < bits; shift += 7 {
+		b, err := r.ReadUInt8()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, ErrVarTooLong
+}
+
+// ReadVarInt16 reads a signed variable-length short (readVarShort)
+func (r *Reader) ReadVarInt16() (int16, error) {
+	v, err := r.ReadVarUInt16()
+	return int16(v), err
+}
+
+// ReadVarUInt16 reads an unsigned variable-length short (readVarUhShort)
+func (r *Reader) ReadVarUInt16() (uint16, error) {
+	v, err := r.readVar(16)
+	return uint16(v), err
+}
+
+// ReadVarInt32 reads a signed variable-length int (readVarInt)
+func (r *Reader) ReadVarInt32() (int32, error) {
+	v, err := r.ReadVarUInt32()
+	return int32(v), err
+}
+
+// ReadVarUInt32 reads an unsigned variable-length int (readVarUhInt)
+func (r *Reader) ReadVarUInt32() (uint32, error) {
+	v, err := r.readVar(32)
+	return uint32(v), err
+}
+
+// ReadVarInt64 reads a signed variable-length long (readVarLong)
+func (r *Reader) ReadVarInt64() (int64, error) {
+	v, err := r.ReadVarUInt64()
+	return int64(v), err
+}
+
+// ReadVarUInt64 reads an unsigned variable-length long (readVarUhLong)
+func (r *Reader) ReadVarUInt64() (uint64, error) {
+	return r.readVar(64)
+}
@@ -0,0 +1,71 @@
+package wire
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReader_Var(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		read    func(r *Reader) (interface{}, error)
+		want    interface{}
+		wantErr bool
+	}{
+		{"VarUInt16 small", []byte{0x05}, func(r *Reader) (interface{}, error) { return r.ReadVarUInt16() }, uint16(5), false},
+		{"VarUInt16 two bytes", []byte{0xAC, 0x02}, func(r *Reader) (interface{}, error) { return r.ReadVarUInt16() }, uint16(300), false},
+		{"VarInt16 negative", []byte{0xFF, 0xFF, 0x03}, func(r *Reader) (interface{}, error) { return r.ReadVarInt16() }, int16(-1), false},
+		{"VarUInt16 too long", []byte{0xFF, 0xFF, 0xFF, 0x01}, func(r *Reader) (interface{}, error) { return r.ReadVarUInt16() }, uint16(0), true},
+		{"VarInt32 negative", []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x0F}, func(r *Reader) (interface{}, error) { return r.ReadVarInt32() }, int32(-1), false},
+		{"VarUInt64", []byte{0x80, 0x80, 0x80, 0x80, 0x10}, func(r *Reader) (interface{}, error) { return r.ReadVarUInt64() }, uint64(1 << 32), false},
+		{"VarUInt32 EOF", []byte{0x80}, func(r *Reader) (interface{}, error) { return r.ReadVarUInt32() }, uint32(0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.read(NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_Fixed(t *testing.T) {
+	r := NewReader([]byte{
+		0xFF,       // int8
+		0x01, 0x2C, // uint16
+		0xFF, 0xFF, 0xFF, 0xFE, // int32
+		0x3F, 0xF0, 0, 0, 0, 0, 0, 0, // double
+		0x00, 0x02, 'o', 'k', // string
+		0x01, // bool
+	})
+	if v, err := r.ReadInt8(); err != nil || v != -1 {
+		t.Errorf("ReadInt8() = %v, %v", v, err)
+	}
+	if v, err := r.ReadUInt16(); err != nil || v != 300 {
+		t.Errorf("ReadUInt16() = %v, %v", v, err)
+	}
+	if v, err := r.ReadInt32(); err != nil || v != -2 {
+		t.Errorf("ReadInt32() = %v, %v", v, err)
+	}
+	if v, err := r.ReadDouble(); err != nil || v != 1 {
+		t.Errorf("ReadDouble() = %v, %v", v, err)
+	}
+	if v, err := r.ReadString(); err != nil || v != "ok" {
+		t.Errorf("ReadString() = %v, %v", v, err)
+	}
+	if v, err := r.ReadBoolean(); err != nil || !v {
+		t.Errorf("ReadBoolean() = %v, %v", v, err)
+	}
+	if r.Len() != 0 {
+		t.Errorf("expected empty reader, %v bytes remaining", r.Len())
+	}
+	if _, err := r.ReadUInt8(); err == nil {
+		t.Errorf("expected error on empty reader")
+	}
+}
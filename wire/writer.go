@@ -0,0 +1,138 @@
+package wire
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrStringTooLong means that a string does not fit in a writeUTF length prefix
+var ErrStringTooLong = errors.New("string longer than 65535 bytes")
+
+// Writer writes Dofus 2 primitive types to a growing buffer. The zero value
+// is an empty Writer ready to use.
+type Writer struct {
+	buf []byte
+}
+
+// NewWriter returns an empty Writer
+func NewWriter() *Writer {
+	return &Writer{}
+}
+
+// Bytes returns the written bytes. The slice aliases the writer buffer.
+func (w *Writer) Bytes() []byte {
+	return w.buf
+}
+
+// Len returns the number of written bytes
+func (w *Writer) Len() int {
+	return len(w.buf)
+}
+
+// WriteBytes writes raw bytes
+func (w *Writer) WriteBytes(b []byte) {
+	w.buf = append(w.buf, b...)
+}
+
+// WriteBoolean writes a boolean on a single byte
+func (w *Writer) WriteBoolean(v bool) {
+	if v {
+		w.WriteUInt8(1)
+	} else {
+		w.WriteUInt8(0)
+	}
+}
+
+// WriteInt8 writes a signed byte (writeByte)
+func (w *Writer) WriteInt8(v int8) {
+	w.WriteUInt8(uint8(v))
+}
+
+// WriteUInt8 writes an unsigned byte (writeByte)
+func (w *Writer) WriteUInt8(v uint8) {
+	w.buf = append(w.buf, v)
+}
+
+// WriteInt16 writes a big-endian signed short (writeShort)
+func (w *Writer) WriteInt16(v int16) {
+	w.WriteUInt16(uint16(v))
+}
+
+// WriteUInt16 writes a big-endian unsigned short (writeShort)
+func (w *Writer) WriteUInt16(v uint16) {
+	w.buf = append(w.buf, byte(v>>8), byte(v))
+}
+
+// WriteInt32 writes a big-endian signed int (writeInt)
+func (w *Writer) WriteInt32(v int32) {
+	w.WriteUInt32(uint32(v))
+}
+
+// WriteUInt32 writes a big-endian unsigned int (writeUnsignedInt)
+func (w *Writer) WriteUInt32(v uint32) {
+	w.buf = append(w.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// WriteFloat writes a big-endian IEEE 754 single precision number
+func (w *Writer) WriteFloat(v float32) {
+	w.WriteUInt32(math.Float32bits(v))
+}
+
+// WriteDouble writes a big-endian IEEE 754 double precision number
+func (w *Writer) WriteDouble(v float64) {
+	bits := math.Float64bits(v)
+	for shift := uint(56); ; shift -= 8 {
+		w.buf = append(w.buf, byte(bits>>shift))
+		if shift == 0 {
+			break
+		}
+	}
+}
+
+// WriteString writes an UTF-8 string prefixed by its unsigned short length (writeUTF)
+func (w *Writer) WriteString(v string) error {
+	if len(v) > math.MaxUint16 {
+		return ErrStringTooLong
+	}
+	w.WriteUInt16(uint16(len(v)))
+	w.buf = append(w.buf, v...)
+	return nil
+}
+
+func (w *Writer) writeVar(v uint64) {
+	for v >= 0x80 {
+		w.buf = append(w.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	w.buf = append(w.buf, byte(v))
+}
+
+// WriteVarInt16 writes a signed variable-length short (writeVarShort)
+func (w *Writer) WriteVarInt16(v int16) {
+	w.writeVar(uint64(uint16(v)))
+}
+
+// WriteVarUInt16 writes an unsigned variable-length short (writeVarShort)
+func (w *Writer) WriteVarUInt16(v uint16) {
+	w.writeVar(uint64(v))
+}
+
+// WriteVarInt32 writes a signed variable-length int (writeVarInt)
+func (w *Writer) WriteVarInt32(v int32) {
+	w.writeVar(uint64(uint32(v)))
+}
+
+// WriteVarUInt32 writes an unsigned variable-length int (writeVarInt)
+func (w *Writer) WriteVarUInt32(v uint32) {
+	w.writeVar(uint64(v))
+}
+
+// WriteVarInt64 writes a signed variable-length long (writeVarLong)
+func (w *Writer) WriteVarInt64(v int64) {
+	w.writeVar(uint64(v))
+}
+
+// WriteVarUInt64 writes an unsigned variable-length long (writeVarLong)
+func (w *Writer) WriteVarUInt64(v uint64) {
+	w.writeVar(v)
+}
@@ -0,0 +1,55 @@
+package wire
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriter_RoundTrip(t *testing.T) {
+	w := NewWriter()
+	w.WriteInt8(-1)
+	w.WriteUInt16(300)
+	w.WriteInt32(-2)
+	w.WriteDouble(1)
+	if err := w.WriteString("ok"); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteBoolean(true)
+	w.WriteVarInt16(-1)
+	w.WriteVarUInt32(300)
+	w.WriteVarInt64(-1)
+
+	want := []byte{
+		0xFF,
+		0x01, 0x2C,
+		0xFF, 0xFF, 0xFF, 0xFE,
+		0x3F, 0xF0, 0, 0, 0, 0, 0, 0,
+		0x00, 0x02, 'o', 'k',
+		0x01,
+		0xFF, 0xFF, 0x03,
+		0xAC, 0x02,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01,
+	}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Fatalf("Writer.Bytes() = %x, want %x", w.Bytes(), want)
+	}
+
+	r := NewReader(w.Bytes())
+	r.ReadBytes(20)
+	if v, err := r.ReadVarInt16(); err != nil || v != -1 {
+		t.Errorf("ReadVarInt16() = %v, %v", v, err)
+	}
+	if v, err := r.ReadVarUInt32(); err != nil || v != 300 {
+		t.Errorf("ReadVarUInt32() = %v, %v", v, err)
+	}
+	if v, err := r.ReadVarInt64(); err != nil || v != -1 {
+		t.Errorf("ReadVarInt64() = %v, %v", v, err)
+	}
+}
+
+func TestWriter_WriteString_TooLong(t *testing.T) {
+	var w Writer
+	if err := w.WriteString(string(make([]byte, 1<<16))); err != ErrStringTooLong {
+		t.Errorf("expected ErrStringTooLong, got %v", err)
+	}
+}